@@ -3,10 +3,13 @@ package gate
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -22,11 +25,25 @@ type epInit struct {
 type App struct {
 	http.Server
 	router      *httprouter.Router
-	paths       openapi3.Paths
 	Info        *openapi3.Info
 	middlewares []*Middleware
 	mwareIndex  map[string]int
 	epCache     []epInit
+	codecs      *codecRegistry
+	maxBodySize int64
+	errs        *errorHandling
+	// Security schemes by name. See App.AddSecurityScheme
+	securitySchemes map[string]*openapi3.SecurityScheme
+	// Apps mounted with MountApp
+	mounts []mountedApp
+	// Routes added by ServeDocs
+	docsPaths []string
+	mu        sync.Mutex
+	// Set to 1 once the endpoints are mounted for serving. The
+	// router can't change after that
+	serving int32
+	// Why mounting failed. Requests get a 500 when set
+	serveErr error
 }
 
 // Conforms with the type accepted by the panic handler of httprouter
@@ -46,6 +63,18 @@ type AppOptions struct {
 	ErrorLog          *log.Logger
 	BaseContext       func(net.Listener) context.Context
 	ConnContext       func(ctx context.Context, c net.Conn) context.Context
+	// When not empty the OpenAPI document and a docs page
+	// are served under this prefix. See App.ServeDocs
+	DocsPrefix string
+	// Default limit on request bodies. Endpoints override it using
+	// EndpointConfig.MaxBodySize. Zero means no limit
+	MaxBodySize int64
+	// Write errors as RFC 7807 application/problem+json and
+	// document the problem schema on every operation
+	ProblemDetails bool
+	// Send the messages of errors that aren't a *Error or mapped using
+	// App.MapError to clients. They're only logged by default
+	ExposeErrors bool
 }
 
 func (ao AppOptions) server() *http.Server {
@@ -184,7 +213,18 @@ func New(ao AppOptions) (*App, error) {
 	if app.Info.Version == "" {
 		return nil, wrapErr(fmt.Errorf("AppOptions.Info.Version cannot be empty"))
 	}
-	app.paths = openapi3.Paths{}
+	app.codecs = newCodecRegistry()
+	app.maxBodySize = ao.MaxBodySize
+	app.errs = &errorHandling{
+		problems: ao.ProblemDetails,
+		expose:   ao.ExposeErrors,
+	}
+
+	if ao.DocsPrefix != "" {
+		if err := app.ServeDocs(ao.DocsPrefix); err != nil {
+			return nil, wrapErr(err)
+		}
+	}
 	return app, nil
 }
 
@@ -237,48 +277,227 @@ func (a *App) FromServer(server *http.Server) {
 	a.TLSNextProto = server.TLSNextProto
 }
 
-// Implements http.Handler interface
+// Implements http.Handler interface. The first request mounts
+// the endpoints. Registering endpoints after that panics. When
+// mounting fails every request gets StatusInternalServerError.
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&a.serving) == 0 {
+		if first, err := a.startServing(); first && err != nil {
+			log.Println(wrapErr(err))
+		}
+	}
+	if a.serveErr != nil {
+		http.Error(w, httpStatusMessage[StatusInternalServerError], StatusInternalServerError)
+		return
+	}
 	a.router.ServeHTTP(w, r)
 }
 
-func errorHandler(rc *RequestCtx, err error) error {
-	code := StatusInternalServerError
-	if e, ok := err.(*Error); ok {
-		code = e.Code
+// Mounts the endpoints and freezes the router on the first call.
+// Returns whether this call mounted them and the outcome of doing so
+func (app *App) startServing() (bool, error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.frozen() {
+		return false, app.serveErr
+	}
+	app.serveErr = app.mountEndpoints()
+	atomic.StoreInt32(&app.serving, 1)
+	return true, app.serveErr
+}
+
+// Reports whether the app started serving. Must hold app.mu
+func (app *App) frozen() bool {
+	return atomic.LoadInt32(&app.serving) == 1
+}
+
+// Adds every endpoint to the router. Must hold app.mu
+func (app *App) mountEndpoints() error {
+	if _, err := app.names(); err != nil {
+		return err
 	}
-	rc.ResponseWriter.WriteHeader(code)
-	if _, err := rc.ResponseWriter.Write([]byte(err.Error())); err != nil {
+	if _, err := app.documentPaths(newSchemaGen()); err != nil {
 		return err
 	}
+	for _, v := range app.epCache {
+		ep, err := app.prepare(v.ec)
+		if err != nil {
+			return err
+		}
+		if err := ep.mount(v.f); err != nil {
+			return wrapErr(err, ep.method, ep.path)
+		}
+	}
+	return nil
+}
+
+// Builds the endpoint ec describes using the app's middlewares,
+// codecs and defaults. Must hold app.mu
+func (app *App) prepare(ec EndpointConfig) (*endpoint, error) {
+	if ec.err != nil {
+		return nil, wrapErr(ec.err, ec.method, ec.Path)
+	}
+	ms := ec.middlewares(app.middlewares)
+	if err := checkMiddlewareIDs(ms); err != nil {
+		return nil, wrapErr(err, ec.method, ec.Path)
+	}
+	applied := ec.applyMiddlerwares(ms)
+	ep := ec.endpoint()
+	ep.middlewares = applied
+	if ep.maxBodySize == 0 {
+		ep.maxBodySize = app.maxBodySize
+	}
+	ep.errs = app.errs
+	if err := app.useCodecs(ep); err != nil {
+		return nil, wrapErr(err, ep.method, ep.path)
+	}
+	return ep, nil
+}
+
+// Registers c for its ContentType replacing any codec registered
+// before. The payloads gate provides encode using these codecs.
+// JSON, XML, msgpack and protobuf codecs are registered by default.
+func (app *App) RegisterCodec(c Codec) error {
+	if c == nil {
+		return wrapErr(fmt.Errorf("nil codec"))
+	}
+	if app.codecs == nil {
+		return wrapErr(fmt.Errorf("app not initialized"))
+	}
+	app.codecs.register(c)
 	return nil
 }
 
-// Called before listen
-func (app *App) mountEndpoints() {
+// Returns the codec registered for ct
+func (app *App) Codec(ct ContentType) (Codec, bool) {
+	return app.codecs.get(ct)
+}
+
+// Resolves the ContentTypes of ep to the app's codecs
+func (app *App) useCodecs(ep *endpoint) error {
+	ep.registry = app.codecs
+	if len(ep.contentTypes) == 0 {
+		return nil
+	}
+	codecs := append([]Codec{}, ep.codecs...)
+	for _, ct := range ep.contentTypes {
+		c, ok := app.codecs.get(ct)
+		if !ok {
+			return wrapErr(fmt.Errorf("no codec registered for %s", ct))
+		}
+		codecs = append(codecs, c)
+	}
+	ep.codecs = codecs
+	return nil
+}
+
+// The paths documenting the app's endpoints. Schemas are added
+// to sg. Must hold app.mu
+func (app *App) documentPaths(sg *schemaGen) (openapi3.Paths, error) {
+	paths := openapi3.Paths{}
+	// operationID -> "METHOD path" of the operation using it
+	operationIDs := map[string]string{}
 	for _, v := range app.epCache {
-		v.ec.applyMiddlerwares(app.middlewares)
-		ep := v.ec.endpoint()
-		ep.handle(v.f)
+		ep, err := app.prepare(v.ec)
+		if err != nil {
+			return nil, err
+		}
+		if v.ec.undocumented {
+			continue
+		}
+		if err := addOperation(paths, operationIDs, sg, ep); err != nil {
+			return nil, wrapErr(err, ep.method, ep.path)
+		}
+	}
+	return paths, nil
+}
+
+// Adds the operation describing ep to paths
+func addOperation(paths openapi3.Paths, operationIDs map[string]string, sg *schemaGen, ep *endpoint) error {
+	if err := ep.validateParams(); err != nil {
+		return wrapErr(err)
+	}
+	op, err := ep.operation(sg)
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	if op.OperationID != "" {
+		if o, ok := operationIDs[op.OperationID]; ok {
+			return wrapErr(fmt.Errorf("operationId %q already used by %s", op.OperationID, o))
+		}
+		operationIDs[op.OperationID] = ep.method + " " + ep.path
 	}
+
+	route, _ := ep.pathDetails()
+	pi, ok := paths[route]
+	if !ok {
+		pi = &openapi3.PathItem{}
+		paths[route] = pi
+	}
+	pi.SetOperation(ep.method, op)
+	return nil
+}
+
+// Returns the OpenAPI document describing every endpoint
+// registered with the app. It's built from the registered
+// endpoints as they are, without mounting them. The document
+// is validated before it's returned.
+func (app *App) OpenAPI() (*openapi3.T, error) {
+	if app == nil || app.router == nil {
+		return nil, wrapErr(fmt.Errorf("app not initialized"))
+	}
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if _, err := app.names(); err != nil {
+		return nil, wrapErr(err)
+	}
+	sg := newSchemaGen()
+	paths, err := app.documentPaths(sg)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	schemas := openapi3.Schemas{}
+	for k, v := range sg.components {
+		schemas[k] = v
+	}
+	schemes := openapi3.SecuritySchemes{}
+	for k, v := range app.securitySchemes {
+		schemes[k] = &openapi3.SecuritySchemeRef{Value: v}
+	}
+	info := *app.Info
+	t := &openapi3.T{
+		OpenAPI: openapiVersion,
+		Info:    &info,
+		Paths:   paths,
+		Components: openapi3.Components{
+			Schemas:         schemas,
+			SecuritySchemes: schemes,
+		},
+	}
+	if err := app.mergeMounts(t); err != nil {
+		return nil, wrapErr(err)
+	}
+	if err := t.Validate(context.Background()); err != nil {
+		return nil, wrapErr(err)
+	}
+	return t, nil
 }
 
 func (app *App) registerEndpoint(
 	ec EndpointConfig,
 	f func(string, httprouter.Handle),
 ) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.frozen() {
+		panic(wrapErr(fmt.Errorf("%s %s registered after the app started serving", ec.method, ec.Path)))
+	}
 	app.epCache = append(app.epCache, epInit{
 		ec: ec,
 		f:  f,
 	})
-	// ep := ec.endpoint()
-	// ep.update(method, route, h, payloadInputs...)
-	// ep.handle(f)
-	// pi, err := ep.pathItem()
-	// if err != nil {
-	// 	return wrapErr(err)
-	// }
-	// app.paths[ec.Route] = pi
 }
 
 // Add a GET endpoint
@@ -352,8 +571,15 @@ func (app *App) addMiddleware(m *Middleware) error {
 // This function is used to add middlewares.
 // The order in which middlewares are added is important.
 // The first middleware added ("Apply"-ed) will be called first
-// and so on.
+// and so on. Middlewares must be applied before the app starts
+// serving. They apply to every endpoint, including ones
+// registered earlier.
 func (app *App) Apply(ms ...*Middleware) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.frozen() {
+		return wrapErr(fmt.Errorf("middlewares applied after the app started serving"))
+	}
 	for _, m := range ms {
 		if err := app.addMiddleware(m); err != nil {
 			return wrapErr(err)
@@ -362,8 +588,68 @@ func (app *App) Apply(ms ...*Middleware) error {
 	return nil
 }
 
+// Adds a security scheme to the OpenAPI document. Operations refer
+// to it by name in EndpointConfig.Security and Group.WithSecurity.
+func (app *App) AddSecurityScheme(name string, s *openapi3.SecurityScheme) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.securitySchemes == nil {
+		app.securitySchemes = map[string]*openapi3.SecurityScheme{}
+	}
+	app.securitySchemes[name] = s
+}
+
+// Replaces how errors are written. h receives the errors Handlers
+// return as well as the *Error and *ValidationError values gate
+// rejects requests with. Use App.ErrorStatus for the status code
+// the default handler would pick and App.DefaultErrorHandler to
+// fall back to it.
+func (app *App) SetErrorHandler(h ErrorHandler) {
+	app.errs.setHandler(h)
+}
+
+// Writes err as the app would without a custom ErrorHandler
+func (app *App) DefaultErrorHandler(rc *RequestCtx, err error) {
+	app.errs.render(rc, err)
+}
+
+// The status code err is answered with by default
+func (app *App) ErrorStatus(err error) int {
+	return app.errs.status(err)
+}
+
+// Answers errors matching target using errors.Is with code.
+// Their message is sent to the client. Mappings are tried in
+// the order they were added.
+func (app *App) MapError(target error, code int) {
+	app.errs.addMapping(errorMapping{
+		match: func(err error) bool {
+			return errors.Is(err, target)
+		},
+		code: code,
+	})
+}
+
+// Answers errors of type T, as found by errors.As, with code.
+// Their message is sent to the client.
+func MapErrorType[T error](app *App, code int) {
+	app.errs.addMapping(errorMapping{
+		match: func(err error) bool {
+			var t T
+			return errors.As(err, &t)
+		},
+		code: code,
+	})
+}
+
 // Used to set a global handler for the HTTP Method of type OPTIONS.
+// Panics once the app started serving.
 func (app *App) SetGlobalOptionsHandler(h Handler) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.frozen() {
+		panic(wrapErr(fmt.Errorf("global OPTIONS handler set after the app started serving")))
+	}
 	app.router.GlobalOPTIONS = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		rc := RequestCtx{
 			ResponseWriter: &ResponseWriter{
@@ -382,9 +668,7 @@ func (app *App) SetGlobalOptionsHandler(h Handler) {
 
 		res, err := h(&rc, &rd)
 		if err != nil {
-			if err := errorHandler(&rc, err); err != nil {
-				log.Println(wrapErr(err))
-			}
+			app.errs.write(&rc, err)
 			return
 		}
 
@@ -392,17 +676,20 @@ func (app *App) SetGlobalOptionsHandler(h Handler) {
 			return
 		}
 
+		code := rc.status
+		if code == 0 {
+			code = StatusOK
+		}
+
 		var bs []byte
-		if res != nil {
+		if res != nil && bodyAllowed(code) {
 			bs, err = res.Marshal()
 			if err != nil {
-				if err := errorHandler(&rc, err); err != nil {
-					log.Println(wrapErr(err))
-				}
+				app.errs.write(&rc, err)
 				return
 			}
 		}
-		rc.ResponseWriter.WriteHeader(StatusOK)
+		rc.ResponseWriter.WriteHeader(code)
 		rc.ResponseWriter.Write(bs)
 	})
 }
@@ -417,7 +704,9 @@ func (app *App) Listen() error {
 		return wrapErr(fmt.Errorf("app not initialized"))
 	}
 
-	app.mountEndpoints()
+	if _, err := app.startServing(); err != nil {
+		return wrapErr(err)
+	}
 	if app.TLSConfig != nil {
 		conn, err := net.Listen("tcp", app.Addr)
 		if err != nil {
@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
 )
@@ -308,6 +314,8 @@ func TestListen(t *testing.T) {
 				}
 			}()
 
+			waitForServer(t, "localhost"+tst.ao.Addr)
+
 			var (
 				r *http.Request
 			)
@@ -377,3 +385,146 @@ func TestListen(t *testing.T) {
 		})
 	}
 }
+
+// Blocks till something is listening at addr
+func waitForServer(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server at %s never came up", addr)
+}
+
+func TestServingFreezesRegistration(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/a", testHandler))
+	if _, err := app.OpenAPI(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.URL("a"); err == nil {
+		t.Fatalf("unnamed endpoint found")
+	}
+	// Registering stays possible until the app serves and
+	// middlewares reach endpoints registered earlier
+	if err := app.Apply(testOrderMiddleware("late")); err != nil {
+		t.Fatal(err)
+	}
+	app.Get(NewEndpointConfig("/b", testHandler))
+
+	for _, p := range []string{"/a", "/b"} {
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, p, nil))
+		if rw.Code != StatusOK || rw.Header().Get("X-Order") != "late" {
+			t.Fatalf("%s: got %d %q", p, rw.Code, rw.Header().Get("X-Order"))
+		}
+	}
+	if err := app.Apply(testOrderMiddleware("later")); err == nil {
+		t.Fatalf("middleware applied after the app started serving")
+	}
+
+	type tt struct {
+		name     string
+		register func()
+	}
+	tsts := []tt{
+		{
+			name:     "endpoint",
+			register: func() { app.Get(NewEndpointConfig("/c", testHandler)) },
+		}, {
+			name:     "options handler",
+			register: func() { app.SetGlobalOptionsHandler(testHandler) },
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("registered after the app started serving")
+				}
+			}()
+			tst.register()
+		})
+	}
+	if err := app.ServeDocs("/docs"); err == nil {
+		t.Fatalf("docs served after the app started serving")
+	}
+}
+
+// Run with -race
+func TestRegisterWhileServing(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/", testHandler))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	get := func(p string) error {
+		res, err := http.Get(srv.URL + p)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode != StatusOK {
+			return fmt.Errorf("%s: got %d", p, res.StatusCode)
+		}
+		return nil
+	}
+	if err := get("/"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := get("/"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registered after the app started serving")
+				}
+			}()
+			app.Get(NewEndpointConfig(fmt.Sprintf("/r%d", i), testHandler))
+		}()
+		// Hands the old lazy mounting a request to race with
+		get("/")
+	}
+	wg.Wait()
+}
+
+func TestServeMountError(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/x/:id", testHandler).WithOperationID("byID"))
+	app.Get(NewEndpointConfig("/x/:name", testHandler).WithOperationID("byName"))
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/x/1", nil))
+		if rw.Code != StatusInternalServerError {
+			t.Fatalf("wanted: %d. got: %d", StatusInternalServerError, rw.Code)
+		}
+	}
+	if n := strings.Count(logs.String(), "conflicts"); n != 1 {
+		t.Fatalf("wanted the route conflict logged once. got: %q", logs.String())
+	}
+	if err := app.Listen(); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("wanted the route conflict. got: %v", err)
+	}
+}
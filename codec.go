@@ -0,0 +1,317 @@
+package gate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+
+	json "github.com/goccy/go-json"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encodes and decodes payloads of one media type. Codecs let an
+// endpoint accept and produce encodings other than the one its
+// payloads implement. See EndpointConfig.Codecs
+type Codec interface {
+	ContentType() ContentType
+	Encode(v interface{}) ([]byte, error)
+	Decode(src []byte, v interface{}) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func (JSONCodec) Encode(v interface{}) ([]byte, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (JSONCodec) Decode(src []byte, v interface{}) error {
+	if err := json.Unmarshal(src, v); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() ContentType {
+	return ContentTypeXML
+}
+
+func (XMLCodec) Encode(v interface{}) ([]byte, error) {
+	bs, err := xml.Marshal(v)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (XMLCodec) Decode(src []byte, v interface{}) error {
+	if err := xml.Unmarshal(src, v); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ContentType() ContentType {
+	return ContentTypeMSGPACK
+}
+
+func (MsgpackCodec) Encode(v interface{}) ([]byte, error) {
+	bs, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (MsgpackCodec) Decode(src []byte, v interface{}) error {
+	if err := msgpack.Unmarshal(src, v); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// Works with payloads implementing proto.Message only
+type ProtoCodec struct{}
+
+func (ProtoCodec) ContentType() ContentType {
+	return ContentTypePROTO
+}
+
+func (ProtoCodec) Encode(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, wrapErr(fmt.Errorf("%T is not a proto.Message", v))
+	}
+	bs, err := proto.Marshal(m)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (ProtoCodec) Decode(src []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return wrapErr(fmt.Errorf("%T is not a proto.Message", v))
+	}
+	if err := proto.Unmarshal(src, m); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// Implemented by the payloads gate provides. They encode their values
+// with whichever codec the app registered for their ContentType.
+// Their Marshal and Unmarshal methods fall back to JSONCodec.
+type codecPayload interface {
+	encode(c Codec) ([]byte, error)
+	decode(c Codec, src []byte) error
+}
+
+// Codecs keyed by media type
+type codecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+func newCodecRegistry() *codecRegistry {
+	cr := &codecRegistry{codecs: map[string]Codec{}}
+	for _, c := range []Codec{JSONCodec{}, XMLCodec{}, MsgpackCodec{}, ProtoCodec{}} {
+		cr.register(c)
+	}
+	return cr
+}
+
+func (cr *codecRegistry) register(c Codec) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.codecs[mediaType(c.ContentType().String())] = c
+}
+
+func (cr *codecRegistry) get(ct ContentType) (Codec, bool) {
+	if cr == nil {
+		return nil, false
+	}
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	c, ok := cr.codecs[mediaType(ct.String())]
+	return c, ok
+}
+
+// The codec registered for JSON. JSONCodec when there's none
+func (cr *codecRegistry) json() Codec {
+	if c, ok := cr.get(ContentTypeJSON); ok {
+		return c
+	}
+	return JSONCodec{}
+}
+
+// Encodes and decodes using the payload's own methods. Payloads gate
+// provides use the codec registered for ct instead.
+type payloadCodec struct {
+	ct  ContentType
+	reg *codecRegistry
+}
+
+func (pc payloadCodec) ContentType() ContentType {
+	return pc.ct
+}
+
+func (pc payloadCodec) Encode(v interface{}) ([]byte, error) {
+	if cp, ok := v.(codecPayload); ok {
+		if c, ok := pc.reg.get(pc.ct); ok {
+			return cp.encode(c)
+		}
+	}
+	p, ok := v.(Payload)
+	if !ok {
+		return nil, wrapErr(fmt.Errorf("%T is not a Payload", v))
+	}
+	return p.Marshal()
+}
+
+func (pc payloadCodec) Decode(src []byte, v interface{}) error {
+	if cp, ok := v.(codecPayload); ok {
+		if c, ok := pc.reg.get(pc.ct); ok {
+			return cp.decode(c, src)
+		}
+	}
+	p, ok := v.(Payload)
+	if !ok {
+		return wrapErr(fmt.Errorf("%T is not a Payload", v))
+	}
+	return p.Unmarshal(src)
+}
+
+// The codecs available for p. The payload's own encoding comes first
+// and is the default. Codecs of the same media type are left out.
+func payloadCodecs(p Payload, codecs []Codec, reg *codecRegistry) []Codec {
+	cs := []Codec{payloadCodec{ct: p.ContentType(), reg: reg}}
+	seen := map[string]bool{mediaType(p.ContentType().String()): true}
+	for _, c := range codecs {
+		mt := mediaType(c.ContentType().String())
+		if seen[mt] {
+			continue
+		}
+		seen[mt] = true
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// The media type of a Content-Type value without its parameters
+func mediaType(v string) string {
+	mt, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(v))
+	}
+	return mt
+}
+
+// Picks the codec matching the request's Content-Type. An empty
+// header picks the first one.
+func requestCodec(contentType string, cs []Codec) (Codec, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return cs[0], true
+	}
+	return codecFor(mediaType(contentType), cs)
+}
+
+func codecFor(mt string, cs []Codec) (Codec, bool) {
+	for _, c := range cs {
+		if mediaType(c.ContentType().String()) == mt {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// The media types of cs as listed in OpenAPI content maps
+func codecTypes(cs []Codec) []string {
+	ts := make([]string, len(cs))
+	for i, c := range cs {
+		ts[i] = c.ContentType().String()
+	}
+	return ts
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ars []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		ar := acceptRange{mediaType: mt, q: 1}
+		if q, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(q, 64); err == nil {
+				ar.q = f
+			}
+		}
+		ars = append(ars, ar)
+	}
+	return ars
+}
+
+func (ar acceptRange) matches(mt string) bool {
+	switch {
+	case ar.mediaType == "*/*", ar.mediaType == mt:
+		return true
+	case strings.HasSuffix(ar.mediaType, "/*"):
+		return strings.HasPrefix(mt, strings.TrimSuffix(ar.mediaType, "*"))
+	}
+	return false
+}
+
+// The weight of the most specific range matching mt
+func acceptWeight(ars []acceptRange, mt string) float64 {
+	q, specificity := 0.0, -1
+	for _, ar := range ars {
+		if !ar.matches(mt) {
+			continue
+		}
+		if s := 2 - strings.Count(ar.mediaType, "*"); s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+// Picks the codec best matching the request's Accept header. Ties go
+// to the earlier codec. An empty header picks the first one.
+func responseCodec(accept string, cs []Codec) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return cs[0], true
+	}
+	ars := parseAccept(accept)
+	var (
+		best  Codec
+		bestQ float64
+	)
+	for _, c := range cs {
+		if q := acceptWeight(ars, mediaType(c.ContentType().String())); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, best != nil
+}
@@ -0,0 +1,347 @@
+package gate
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	app := newTestApp(t)
+	app.Post(EndpointConfig{
+		Path: "/echo",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			return rd.Body, nil
+		},
+		Payload: EndpointPayload{
+			RequestPayload:  &testPld{},
+			ResponsePayload: &testPld{},
+		},
+	}.WithCodecs(XMLCodec{}, MsgpackCodec{}))
+
+	in := &testPld{Key: "k", Value: "v"}
+	encode := func(c Codec) string {
+		bs, err := c.Encode(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+
+	type tt struct {
+		name        string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		outType     ContentType
+		out         string
+	}
+	tsts := []tt{
+		{
+			name:    "defaults",
+			body:    encode(JSONCodec{}),
+			status:  StatusOK,
+			outType: ContentTypeJSON,
+			out:     encode(JSONCodec{}),
+		}, {
+			name:        "xml in json out",
+			contentType: "application/xml; charset=utf-8",
+			accept:      "application/json",
+			body:        encode(XMLCodec{}),
+			status:      StatusOK,
+			outType:     ContentTypeJSON,
+			out:         encode(JSONCodec{}),
+		}, {
+			name:        "json in msgpack out",
+			contentType: "application/json",
+			accept:      "text/html, application/msgpack;q=0.9, */*;q=0.1",
+			body:        encode(JSONCodec{}),
+			status:      StatusOK,
+			outType:     ContentTypeMSGPACK,
+			out:         encode(MsgpackCodec{}),
+		}, {
+			name:        "wildcard picks the default",
+			contentType: "application/msgpack",
+			accept:      "*/*",
+			body:        encode(MsgpackCodec{}),
+			status:      StatusOK,
+			outType:     ContentTypeJSON,
+			out:         encode(JSONCodec{}),
+		}, {
+			name:    "refused default",
+			accept:  "application/json;q=0, application/*",
+			body:    encode(JSONCodec{}),
+			status:  StatusOK,
+			outType: ContentTypeXML,
+			out:     encode(XMLCodec{}),
+		}, {
+			name:        "unsupported media type",
+			contentType: "text/csv",
+			body:        "k,v",
+			status:      StatusUnsupportedMediaType,
+		}, {
+			name:   "not acceptable",
+			accept: "text/html",
+			body:   encode(JSONCodec{}),
+			status: StatusNotAcceptable,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(tst.body))
+			if tst.contentType != "" {
+				r.Header.Set(HeaderContentType, tst.contentType)
+			}
+			if tst.accept != "" {
+				r.Header.Set(HeaderAccept, tst.accept)
+			}
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.status != StatusOK {
+				return
+			}
+			if ct := rw.Header().Get(HeaderContentType); ct != tst.outType.String() {
+				t.Fatalf("wanted content type: %s. got: %s", tst.outType, ct)
+			}
+			if rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Paths["/echo"].Post
+	for _, ct := range []ContentType{ContentTypeJSON, ContentTypeXML, ContentTypeMSGPACK} {
+		if op.RequestBody.Value.Content.Get(ct.String()) == nil {
+			t.Fatalf("request %s not documented", ct)
+		}
+		if op.Responses.Get(StatusOK).Value.Content.Get(ct.String()) == nil {
+			t.Fatalf("response %s not documented", ct)
+		}
+	}
+}
+
+func TestNegotiationDeclaredResponses(t *testing.T) {
+	app := newTestApp(t)
+	app.Post(NewEndpointConfig("/items", func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		rc.SetStatus(StatusCreated)
+		return &testPld{Key: "k", Value: "v"}, nil
+	}).WithResponse(StatusCreated, &testPld{}).WithCodecs(XMLCodec{}))
+
+	type tt struct {
+		name    string
+		accept  string
+		status  int
+		outType ContentType
+	}
+	tsts := []tt{
+		{
+			name:    "default",
+			status:  StatusCreated,
+			outType: ContentTypeJSON,
+		}, {
+			name:    "xml",
+			accept:  "application/xml",
+			status:  StatusCreated,
+			outType: ContentTypeXML,
+		}, {
+			name:   "not acceptable",
+			accept: "text/html",
+			status: StatusNotAcceptable,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/items", nil)
+			if tst.accept != "" {
+				r.Header.Set(HeaderAccept, tst.accept)
+			}
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.outType != "" && rw.Header().Get(HeaderContentType) != tst.outType.String() {
+				t.Fatalf("wanted content type: %s. got: %s", tst.outType, rw.Header().Get(HeaderContentType))
+			}
+		})
+	}
+}
+
+// Wraps JSONCodec and counts its calls
+type countingCodec struct {
+	JSONCodec
+	ct      ContentType
+	encodes int
+	decodes int
+}
+
+func (cc *countingCodec) ContentType() ContentType {
+	return cc.ct
+}
+
+func (cc *countingCodec) Encode(v interface{}) ([]byte, error) {
+	cc.encodes++
+	return cc.JSONCodec.Encode(v)
+}
+
+func (cc *countingCodec) Decode(src []byte, v interface{}) error {
+	cc.decodes++
+	return cc.JSONCodec.Decode(src, v)
+}
+
+func TestCodecRegistry(t *testing.T) {
+	app := newTestApp(t)
+	jc := &countingCodec{ct: ContentTypeJSON}
+	tc := &countingCodec{ct: "application/x-test"}
+	for _, c := range []Codec{jc, tc} {
+		if err := app.RegisterCodec(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.RegisterCodec(nil); err == nil {
+		t.Fatalf("nil codec registered")
+	}
+
+	app.Post(EndpointConfig{
+		Path: "/echo",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			return rd.Body, nil
+		},
+		Payload: EndpointPayload{
+			RequestPayload:  NewString(""),
+			ResponsePayload: NewString(""),
+		},
+	}.WithContentTypes("application/x-test"))
+
+	type tt struct {
+		name    string
+		accept  string
+		codec   *countingCodec
+		encodes int
+	}
+	tsts := []tt{
+		{
+			name:    "registered json",
+			codec:   jc,
+			encodes: 1,
+		}, {
+			name:    "registered content type",
+			accept:  "application/x-test",
+			codec:   tc,
+			encodes: 1,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(`"yolo"`))
+			if tst.accept != "" {
+				r.Header.Set(HeaderAccept, tst.accept)
+			}
+			before := tst.codec.encodes
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != StatusOK || rw.Body.String() != `"yolo"` {
+				t.Fatalf("wanted: 200 \"yolo\". got: %d %s", rw.Code, rw.Body.String())
+			}
+			if tst.codec.encodes-before != tst.encodes {
+				t.Fatalf("wanted %d encodes. got: %d", tst.encodes, tst.codec.encodes-before)
+			}
+		})
+	}
+	if jc.decodes != 2 {
+		t.Fatalf("wanted the registered json codec to decode both requests. got: %d", jc.decodes)
+	}
+
+	app = newTestApp(t)
+	app.Get(EndpointConfig{
+		Path:    "/nope",
+		Handler: testHandler,
+		Payload: EndpointPayload{
+			ResponsePayload: &testPld{},
+		},
+	}.WithContentTypes("application/cbor"))
+	if _, err := app.OpenAPI(); err == nil {
+		t.Fatalf("unregistered content type mounted")
+	}
+}
+
+// A query payload decoded from the query's JSON encoding
+type testMapQuery map[string][]string
+
+func (q testMapQuery) Marshal() ([]byte, error) {
+	return JSONCodec{}.Encode(q)
+}
+
+func (q *testMapQuery) Unmarshal(src []byte) error {
+	return JSONCodec{}.Decode(src, q)
+}
+
+func (testMapQuery) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func TestRegisteredJSONCodec(t *testing.T) {
+	app, err := New(AppOptions{
+		Info: openapi3.Info{
+			Title:   "test api",
+			Version: "0.0.0",
+		},
+		ProblemDetails: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jc := &countingCodec{ct: ContentTypeJSON}
+	if err := app.RegisterCodec(jc); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.ServeDocs("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	app.Get(EndpointConfig{
+		Path:    "/query",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) { return nil, nil },
+		Payload: NewEndpointPayload(NOPE(), &testMapQuery{}),
+	})
+	app.Get(NewStreamEndpointConfig("/stream", ContentTypeNDJSON, NewInt(0), func(rc *RequestCtx, w io.WriteCloser) error {
+		return NewNDJSONEncoder(w).Encode(map[string]int{"a": 1})
+	}))
+	app.Get(NewEndpointConfig("/fail", func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		return nil, ErrNotFound
+	}))
+
+	type tt struct {
+		url     string
+		encodes int
+		decodes int
+	}
+	tsts := []tt{
+		{url: "/query?a=1", encodes: 1},
+		{url: "/stream", encodes: 1},
+		{url: "/fail", encodes: 1},
+		{url: "/docs/openapi.json", encodes: 1},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.url, func(t *testing.T) {
+			encodes, decodes := jc.encodes, jc.decodes
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tst.url, nil))
+			if jc.encodes-encodes != tst.encodes || jc.decodes-decodes != tst.decodes {
+				t.Fatalf("wanted %d encodes and %d decodes. got: %d and %d",
+					tst.encodes, tst.decodes, jc.encodes-encodes, jc.decodes-decodes)
+			}
+		})
+	}
+}
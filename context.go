@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -12,7 +13,6 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/goccy/go-json"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -24,6 +24,13 @@ type Deserializable interface {
 	Unmarshal([]byte) error
 }
 
+// Implemented by request payloads that decode straight from the
+// request body. gate prefers it over Unmarshal so large bodies
+// needn't be buffered in memory.
+type StreamDeserializable interface {
+	UnmarshalFrom(io.Reader) error
+}
+
 type Payload interface {
 	Serializable
 	Deserializable
@@ -44,6 +51,10 @@ func (dp NoPayload) Marshal() ([]byte, error) {
 	return nil, nil
 }
 
+func (NoPayload) ContentType() ContentType {
+	return ContentTypeTEXT
+}
+
 type Pagination struct {
 	Page       int32 `json:"page"`
 	ItemCount  int8  `json:"item_count"`
@@ -53,17 +64,25 @@ type Pagination struct {
 }
 
 func (p Pagination) Marshal() ([]byte, error) {
-	bs, err := json.Marshal(p)
+	return p.encode(JSONCodec{})
+}
+
+func (p Pagination) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(p)
 	if err != nil {
-		return nil, wrapErr(err, "json marshal failed")
+		return nil, wrapErr(err, "marshal failed")
 	}
 	return bs, nil
 }
 
 func (p *Pagination) Unmarshal(src []byte) error {
+	return p.decode(JSONCodec{}, src)
+}
+
+func (p *Pagination) decode(c Codec, src []byte) error {
 	var t Pagination
-	if err := json.Unmarshal(src, &t); err != nil {
-		return wrapErr(err, "json unmarshal failed")
+	if err := c.Decode(src, &t); err != nil {
+		return wrapErr(err, "unmarshal failed")
 	}
 	*p = t
 	return nil
@@ -80,9 +99,14 @@ type RequestData struct {
 	Custom      map[string]interface{}
 }
 
-type Handler func(*RequestCtx, *RequestData) (Payload, error)
+// Binds Params into the struct dst points to using `path` tags.
+// Conversion failures are reported as an *Error with StatusBadRequest
+// that can be returned from the handler as is.
+func (rd *RequestData) BindPath(dst interface{}) error {
+	return bindPath(rd.Params, dst)
+}
 
-// type StreamHandler func(*RequestCtx, io.WriteCloser) error
+type Handler func(*RequestCtx, *RequestData) (Payload, error)
 
 var rcPool sync.Pool
 
@@ -144,7 +168,12 @@ func (rw *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if !ok {
 		return nil, nil, wrapErr(fmt.Errorf("ResponseWriter is not a Hijacker"))
 	}
-	return h.Hijack()
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		// Nothing may be written through rw anymore
+		rw.written = true
+	}
+	return conn, brw, err
 }
 
 func (rw *ResponseWriter) Push(target string, opts *http.PushOptions) error {
@@ -168,6 +197,10 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 type RequestCtx struct {
 	Request        *http.Request
 	ResponseWriter *ResponseWriter
+	status         int
+	params         httprouter.Params
+	// The endpoint being served. Nil outside of endpoints
+	ep *endpoint
 }
 
 // Must happen after payload unmarshal
@@ -181,9 +214,52 @@ func (rc *RequestCtx) update(rw http.ResponseWriter, r *http.Request) {
 func (rc *RequestCtx) reset() {
 	rc.Request = nil
 	rc.ResponseWriter = nil
+	rc.status = 0
+	rc.params = nil
+	rc.ep = nil
+}
+
+// Returns the value of the path param name
+func (rc *RequestCtx) Param(name string) string {
+	return rc.params.ByName(name)
+}
+
+// Same as RequestData.BindPath. Useful in typed handlers
+func (rc *RequestCtx) BindPath(dst interface{}) error {
+	return bindPath(rc.params, dst)
+}
+
+// Binds request headers into the struct dst points to using
+// `header` tags. See RequestData.BindPath for the returned error
+func (rc *RequestCtx) BindHeader(dst interface{}) error {
+	return bindHeader(rc.Request.Header, dst)
+}
+
+// Binds request cookies into the struct dst points to using
+// `cookie` tags. See RequestData.BindPath for the returned error
+func (rc *RequestCtx) BindCookie(dst interface{}) error {
+	return bindCookie(rc.Request.Cookies(), dst)
+}
+
+// Sets the status code sent along with the Payload returned by the
+// handler. It should be one of the codes declared in
+// EndpointPayload.Responses. Defaults to StatusOK when not called.
+// This has no effect once the handler writes to rc.ResponseWriter
+// or returns an error. Undeclared codes are sent but logged.
+func (rc *RequestCtx) SetStatus(code int) {
+	rc.status = code
+}
+
+// The app's JSON codec. JSONCodec outside of an endpoint
+func (rc *RequestCtx) jsonCodec() Codec {
+	if rc.ep == nil {
+		return JSONCodec{}
+	}
+	return rc.ep.registry.json()
 }
 
-// Will return 0 until Write or Writeheader is called
+// Will return 0 until Write or Writeheader is called. Use
+// SetStatus to choose the code the returned Payload is sent with
 func (rc *RequestCtx) StatusCode() int {
 	return rc.ResponseWriter.statusCode
 }
@@ -0,0 +1,153 @@
+package gate
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/julienschmidt/httprouter"
+)
+
+//go:embed docs
+var docsFS embed.FS
+
+var docsTemplate = template.Must(template.ParseFS(docsFS, "docs/index.html"))
+
+var docsAssets = []string{"docs.css", "docs.js"}
+
+const ContentTypeYAML ContentType = "application/yaml"
+
+type docsPage struct {
+	Title  string
+	JSON   string
+	YAML   string
+	Assets string
+}
+
+// Registers routes that serve the app's OpenAPI document along with
+// a page rendering it. With the prefix "/docs" these are:
+//
+//	GET /docs              - the docs page
+//	GET /docs/openapi.json - the document as JSON
+//	GET /docs/openapi.yaml - the document as YAML
+//	GET /docs/assets/...   - static files used by the docs page
+//
+// The docs page works offline since everything it needs is embedded.
+// None of these routes show up in the document itself.
+func (app *App) ServeDocs(prefix string) error {
+	if app == nil || app.router == nil {
+		return wrapErr(fmt.Errorf("app not initialized"))
+	}
+
+	base := strings.TrimSuffix("/"+strings.Trim(prefix, "/"), "/")
+	page := docsPage{
+		JSON:   base + "/openapi.json",
+		YAML:   base + "/openapi.yaml",
+		Assets: base + "/assets",
+	}
+
+	pagePath := base
+	if pagePath == "" {
+		pagePath = "/"
+	}
+	routes := []docsRoute{
+		{page.JSON, app.specHandler(ContentTypeJSON)},
+		{page.YAML, app.specHandler(ContentTypeYAML)},
+	}
+	for _, a := range docsAssets {
+		routes = append(routes, docsRoute{page.Assets + "/" + a, docsAssetHandler(a)})
+	}
+	routes = append(routes, docsRoute{pagePath, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		p := page
+		p.Title = app.Info.Title
+		var buf bytes.Buffer
+		if err := docsTemplate.Execute(&buf, p); err != nil {
+			log.Println(wrapErr(err))
+			http.Error(w, httpStatusMessage[StatusInternalServerError], StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeHTML.String())
+		w.Write(buf.Bytes())
+	}})
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.frozen() {
+		return wrapErr(fmt.Errorf("docs served after the app started serving"))
+	}
+	// Endpoints are only routed once the app serves, so the docs
+	// routes served before are all the router has
+	paths := append([]string{}, app.docsPaths...)
+	for _, r := range routes {
+		paths = append(paths, r.path)
+	}
+	if err := checkRoutes(paths); err != nil {
+		return wrapErr(err)
+	}
+	for _, r := range routes {
+		app.router.GET(r.path, r.h)
+		app.docsPaths = append(app.docsPaths, r.path)
+	}
+	return nil
+}
+
+type docsRoute struct {
+	path string
+	h    httprouter.Handle
+}
+
+// Fails with the conflict httprouter panics on when the GET
+// routes paths are registered together
+func checkRoutes(paths []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	r := httprouter.New()
+	for _, p := range paths {
+		r.GET(p, func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+	}
+	return nil
+}
+
+func (app *App) specHandler(ct ContentType) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		doc, err := app.OpenAPI()
+		if err != nil {
+			log.Println(wrapErr(err))
+			http.Error(w, httpStatusMessage[StatusInternalServerError], StatusInternalServerError)
+			return
+		}
+
+		bs, err := app.codecs.json().Encode(doc)
+		if err == nil && ct == ContentTypeYAML {
+			bs, err = yaml.JSONToYAML(bs)
+		}
+		if err != nil {
+			log.Println(wrapErr(err))
+			http.Error(w, httpStatusMessage[StatusInternalServerError], StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ct.String())
+		w.Write(bs)
+	}
+}
+
+func docsAssetHandler(name string) httprouter.Handle {
+	bs, err := docsFS.ReadFile("docs/" + name)
+	if err != nil {
+		panic(wrapErr(err))
+	}
+	ct := mimeExtensions[strings.TrimPrefix(path.Ext(name), ".")]
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Header().Set("Content-Type", ct)
+		w.Write(bs)
+	}
+}
@@ -0,0 +1,177 @@
+(function () {
+  "use strict";
+
+  var METHODS = ["get", "put", "post", "delete", "options", "head", "patch", "trace"];
+  var MAX_DEPTH = 8;
+
+  var root = document.getElementById("docs");
+
+  function el(tag, attrs, children) {
+    var e = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") {
+        e.textContent = attrs[k];
+      } else {
+        e.setAttribute(k, attrs[k]);
+      }
+    });
+    (children || []).forEach(function (c) {
+      if (c) {
+        e.appendChild(c);
+      }
+    });
+    return e;
+  }
+
+  function resolve(spec, s) {
+    if (s && s.$ref) {
+      var name = s.$ref.replace("#/components/schemas/", "");
+      var c = (spec.components && spec.components.schemas) || {};
+      return { name: name, schema: c[name] || {} };
+    }
+    return { name: "", schema: s || {} };
+  }
+
+  // Renders a schema as a TypeScript-ish sketch. Recursive
+  // references are printed by name once they've been expanded.
+  function sketch(spec, s, depth, seen) {
+    var r = resolve(spec, s);
+    s = r.schema;
+    if (r.name) {
+      if (seen[r.name] || depth > MAX_DEPTH) {
+        return r.name;
+      }
+      seen = Object.assign({}, seen);
+      seen[r.name] = true;
+    }
+
+    var pad = new Array(depth + 1).join("  ");
+    var out;
+    if (s.type === "array") {
+      out = sketch(spec, s.items, depth, seen) + "[]";
+    } else if (s.properties) {
+      var req = s.required || [];
+      var lines = Object.keys(s.properties).map(function (k) {
+        var opt = req.indexOf(k) >= 0 ? "" : "?";
+        return pad + "  " + k + opt + ": " + sketch(spec, s.properties[k], depth + 1, seen);
+      });
+      out = "{\n" + lines.join("\n") + "\n" + pad + "}";
+    } else if (s.type === "object" && s.additionalProperties) {
+      out = "{ [key: string]: " + sketch(spec, s.additionalProperties, depth, seen) + " }";
+    } else if (s.oneOf || s.anyOf) {
+      out = (s.oneOf || s.anyOf).map(function (v) {
+        return sketch(spec, v, depth, seen);
+      }).join(" | ");
+    } else {
+      out = s.type || "any";
+      if (s.format) {
+        out += "<" + s.format + ">";
+      }
+    }
+    if (s.enum) {
+      out += " (" + s.enum.map(JSON.stringify).join(" | ") + ")";
+    }
+    if (s.nullable) {
+      out += " | null";
+    }
+    return out;
+  }
+
+  function content(spec, c) {
+    return Object.keys(c || {}).map(function (mt) {
+      return el("div", {}, [
+        el("code", { text: mt }),
+        el("pre", { text: sketch(spec, c[mt].schema, 0, {}) })
+      ]);
+    });
+  }
+
+  function parameters(spec, ps) {
+    if (!ps || !ps.length) {
+      return null;
+    }
+    var rows = ps.map(function (p) {
+      return el("tr", {}, [
+        el("td", {}, [
+          el("code", { text: p.name }),
+          p.required ? el("span", { class: "required", text: " *" }) : null
+        ]),
+        el("td", { text: p.in }),
+        el("td", {}, [el("code", { text: sketch(spec, p.schema, 0, {}) })]),
+        el("td", { text: p.description || "" })
+      ]);
+    });
+    return el("div", {}, [
+      el("h4", { text: "Parameters" }),
+      el("table", {}, [
+        el("tr", {}, ["Name", "In", "Schema", "Description"].map(function (h) {
+          return el("th", { text: h });
+        }))
+      ].concat(rows))
+    ]);
+  }
+
+  function operation(spec, path, method, op) {
+    var summary = el("summary", {}, [
+      el("span", { class: "method " + method, text: method }),
+      el("span", { class: "path", text: path }),
+      el("span", { class: "muted", text: op.summary || "" })
+    ].concat((op.tags || []).map(function (t) {
+      return el("span", { class: "tag", text: t });
+    })));
+
+    var body = [];
+    if (op.description) {
+      body.push(el("p", { text: op.description }));
+    }
+    if (op.operationId) {
+      body.push(el("p", { class: "muted" }, [el("code", { text: op.operationId })]));
+    }
+    body.push(parameters(spec, op.parameters));
+    if (op.requestBody) {
+      body.push(el("h4", { text: "Request body" }));
+      body = body.concat(content(spec, op.requestBody.content));
+    }
+    body.push(el("h4", { text: "Responses" }));
+    Object.keys(op.responses || {}).sort().forEach(function (code) {
+      var res = op.responses[code];
+      body.push(el("p", {}, [
+        el("strong", { text: code + " " }),
+        el("span", { text: res.description || "" })
+      ]));
+      body = body.concat(content(spec, res.content));
+    });
+
+    var cls = "op" + (op.deprecated ? " deprecated" : "");
+    return el("details", { class: cls }, [summary, el("div", { class: "op-body" }, body)]);
+  }
+
+  function render(spec) {
+    root.textContent = "";
+    var info = spec.info || {};
+    document.getElementById("title").textContent = info.title + " " + info.version;
+    if (info.description) {
+      root.appendChild(el("p", { class: "info", text: info.description }));
+    }
+    Object.keys(spec.paths || {}).sort().forEach(function (path) {
+      var item = spec.paths[path];
+      METHODS.forEach(function (m) {
+        if (item[m]) {
+          root.appendChild(operation(spec, path, m, item[m]));
+        }
+      });
+    });
+  }
+
+  fetch(root.getAttribute("data-spec"))
+    .then(function (res) {
+      if (!res.ok) {
+        throw new Error(res.status + " " + res.statusText);
+      }
+      return res.json();
+    })
+    .then(render)
+    .catch(function (err) {
+      root.textContent = "Failed to load the spec: " + err.message;
+    });
+})();
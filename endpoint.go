@@ -6,11 +6,10 @@ import (
 	"log"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
-	json "github.com/goccy/go-json"
-
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/julienschmidt/httprouter"
 )
@@ -28,208 +27,467 @@ type endpoint struct {
 	requestPayload  Payload
 	queryPayload    Payload
 	responsePayload Payload
+	responses       map[int]Payload
 	mexclusions     []string
-	requestPool     sync.Pool
-	queryPool       sync.Pool
+	middlewares     []*Middleware
+	summary         string
+	description     string
+	tags            []string
+	operationID     string
+	deprecated      bool
+	security        openapi3.SecurityRequirements
+	allowEmptyQuery bool
+	params          []Param
+	codecs          []Codec
+	multipart       MultipartLimits
+	maxBodySize     int64
+	contentTypes    []ContentType
+	validate        bool
+	headers         interface{}
+	cookies         interface{}
+	stream          *streamSpec
+	requestPool     payloadPool
+	queryPool       payloadPool
+	// The app's codecs. Set when mounting
+	registry *codecRegistry
+	// The app's error handling. Set when mounting
+	errs *errorHandling
+}
+
+// Hands out Payload instances for an endpoint to unmarshal into
+type payloadPool interface {
+	get() Payload
+	put(Payload)
+}
+
+// Implemented by payloads that can't be copied by value. Pools
+// ask them for new instances and to reset used ones.
+type freshPayload interface {
+	newPayload() Payload
+	resetPayload()
+}
+
+// A payloadPool of copies of sample. Values are reset to sample
+// before they're reused so nothing leaks between requests.
+type samplePool struct {
+	pool   sync.Pool
+	sample Payload
+}
+
+func newSamplePool(sample Payload) *samplePool {
+	sp := &samplePool{sample: sample}
+	sp.pool.New = func() interface{} {
+		if fp, ok := sample.(freshPayload); ok {
+			return fp.newPayload()
+		}
+		val := reflect.ValueOf(sample)
+		if val.Kind() != reflect.Ptr {
+			return sample
+		}
+		v := reflect.New(val.Type().Elem()).Elem()
+		v.Set(val.Elem())
+		return v.Addr().Interface()
+	}
+	return sp
+}
+
+func (sp *samplePool) get() Payload {
+	p, ok := sp.pool.Get().(Payload)
+	if !ok {
+		panic(wrapErr(fmt.Errorf("samplePool returned something that's not a Payload")))
+	}
+	return p
+}
+
+func (sp *samplePool) put(p Payload) {
+	if fp, ok := p.(freshPayload); ok {
+		fp.resetPayload()
+		sp.pool.Put(p)
+		return
+	}
+	val := reflect.ValueOf(p)
+	if val.Kind() != reflect.Ptr {
+		return
+	}
+	val.Elem().Set(reflect.ValueOf(sp.sample).Elem())
+	sp.pool.Put(p)
 }
 
 func (ep *endpoint) initPools() {
-	if ep.requestPayload != nil {
-		ep.requestPool = sync.Pool{
-			New: func() interface{} {
-				inpt := reflect.TypeOf(ep.requestPayload)
-				switch inpt.Kind() {
-				case reflect.Array, reflect.Chan,
-					reflect.Map, reflect.Ptr, reflect.Slice:
-					inpt = inpt.Elem()
-				}
-				val := reflect.ValueOf(ep.requestPayload)
-				v := reflect.New(inpt).Elem()
-				v.Set(val.Elem())
-				return v.Addr()
-			},
+	if ep.requestPool == nil && hasBody(ep.requestPayload) {
+		ep.requestPool = newSamplePool(ep.requestPayload)
+	}
+
+	if ep.queryPool == nil && hasBody(ep.queryPayload) {
+		ep.queryPool = newSamplePool(ep.queryPayload)
+	}
+}
+
+// Makes sure the declared params are usable. Params declared
+// by the Headers and Cookies structs are added to ep.params here.
+func (ep *endpoint) validateParams() error {
+	params := append([]Param{}, ep.params...)
+	for i, sample := range []interface{}{ep.headers, ep.cookies} {
+		if sample == nil {
+			continue
+		}
+		in := PARAM_IN_HEADER
+		if i == 1 {
+			in = PARAM_IN_COOKIE
+		}
+		ps, err := structParams(in, sample)
+		if err != nil {
+			return wrapErr(err)
 		}
+		params = append(params, ps...)
 	}
+	ep.params = params
 
-	if ep.queryPayload != nil {
-		ep.queryPool = sync.Pool{
-			New: func() interface{} {
-				inpt := reflect.TypeOf(ep.queryPayload)
-				switch inpt.Kind() {
-				case reflect.Array, reflect.Chan,
-					reflect.Map, reflect.Ptr, reflect.Slice:
-					inpt = inpt.Elem()
-				}
-				val := reflect.ValueOf(ep.queryPayload)
-				v := reflect.New(inpt).Elem()
-				v.Set(val.Elem())
-				return v.Addr()
-			},
+	_, names := ep.pathDetails()
+	inPath := map[string]bool{}
+	for _, n := range names {
+		inPath[n] = true
+	}
+
+	seen := map[string]bool{}
+	for _, p := range ep.params {
+		k := p.in().String() + ":" + p.name()
+		if p.in() == PARAM_IN_HEADER {
+			k = strings.ToLower(k)
+		}
+		if seen[k] {
+			return wrapErr(fmt.Errorf("%s param %q declared more than once", p.in(), p.name()))
+		}
+		seen[k] = true
+
+		if p.in() == PARAM_IN_PATH && !inPath[p.name()] {
+			return wrapErr(fmt.Errorf("path param %q not in path %s", p.name(), ep.path))
+		}
+		if pt := paramPattern(p); pt != "" {
+			if _, err := compilePattern(pt); err != nil {
+				return wrapErr(err, p.name())
+			}
 		}
 	}
+	return nil
 }
 
-func (ep endpoint) pathDetails() (string, []string) {
-	// qps := queryParams(ep.Payload)
+// Returns the value of p in r. Query params are not looked up
+// here since the QueryPayload handles them.
+func paramValue(p Param, r *http.Request, params httprouter.Params) string {
+	switch p.in() {
+	case PARAM_IN_PATH:
+		return params.ByName(p.name())
+	case PARAM_IN_HEADER:
+		return r.Header.Get(p.name())
+	case PARAM_IN_COOKIE:
+		if c, err := r.Cookie(p.name()); err == nil {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func (ep *endpoint) pathDetails() (string, []string) {
 	params := pathParams(ep.path)
 	if len(params) == 0 {
 		return ep.path, nil
 	}
 	r := ep.path
 	for i, param := range params {
-		fr := fmt.Sprintf("{%s}", strings.Trim(strings.Replace(param, ":", "", 1), "/"))
-		r = strings.Replace(r, param, fr, 1)
 		pname := strings.Trim(strings.Replace(param, ":", "", 1), "/")
+		fr := fmt.Sprintf("/{%s}", pname)
+		r = strings.Replace(r, param, fr, 1)
 		params[i] = pname
 	}
 	return r, params
 }
 
-func (ep endpoint) requestSchema() (openapi3.Schema, error) {
-	s, err := schemaFromType(reflect.TypeOf(ep.handler).In(1))
+func (ep *endpoint) requestSchema(sg *schemaGen) (*openapi3.SchemaRef, error) {
+	s, err := sg.payloadSchema(ep.requestPayload)
 	if err != nil {
-		return *openapi3.NewSchema(), wrapErr(err)
+		return nil, wrapErr(err)
 	}
 	return s, nil
 }
 
-func (ep endpoint) responseSchema() (openapi3.Schema, error) {
-	t := reflect.TypeOf(ep.handler)
-	if t.Kind() != reflect.Func {
-		return openapi3.Schema{}, wrapErr(fmt.Errorf("type if not a func"))
+// Implemented by payloads that read the request body themselves
+type requestDecoder interface {
+	decodeRequest(r *http.Request, ep *endpoint) error
+}
+
+// Implemented by payloads holding resources that must be released
+// once the request is done
+type payloadCleaner interface {
+	cleanupPayload()
+}
+
+// Cuts the request body off after n bytes
+type limitedBody struct {
+	io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.exceeded {
+		return 0, NewError(StatusRequestEntityTooLarge)
 	}
-	s, err := schemaFromType(t.Out(0))
-	if err != nil {
-		return openapi3.Schema{}, wrapErr(err)
+	if int64(len(p)) > lb.n+1 {
+		p = p[:lb.n+1]
 	}
-	return s, nil
+	n, err := lb.ReadCloser.Read(p)
+	if int64(n) > lb.n {
+		lb.exceeded = true
+		return int(lb.n), NewError(StatusRequestEntityTooLarge)
+	}
+	lb.n -= int64(n)
+	return n, err
 }
 
-// func (ep endpoint) generatePathItem() {
-// 	op := openapi3.NewOperation()
-// 	op.OperationID = ep.route
-// 	formattedRoute, params, queryParams := ep.routeDetails()
+// Reads the request body into p using c. Errors the client
+// should see are returned as *Error.
+func (ep *endpoint) readBody(r *http.Request, c Codec, p Payload) error {
+	var lb *limitedBody
+	if ep.maxBodySize > 0 {
+		if r.ContentLength > ep.maxBodySize {
+			return NewError(StatusRequestEntityTooLarge)
+		}
+		lb = &limitedBody{ReadCloser: r.Body, n: ep.maxBodySize}
+		r.Body = lb
+	}
+	tooLarge := func() bool {
+		return lb != nil && lb.exceeded
+	}
+
+	if _, own := c.(payloadCodec); own {
+		if rd, ok := p.(requestDecoder); ok {
+			err := rd.decodeRequest(r, ep)
+			if err != nil && tooLarge() {
+				return NewError(StatusRequestEntityTooLarge)
+			}
+			return err
+		}
+		if sd, ok := p.(StreamDeserializable); ok {
+			if r.ContentLength == 0 {
+				return NewError(StatusBadRequest, "empty payload")
+			}
+			if err := sd.UnmarshalFrom(r.Body); err != nil {
+				if tooLarge() {
+					return NewError(StatusRequestEntityTooLarge)
+				}
+				if e, ok := err.(*Error); ok {
+					return e
+				}
+				log.Println(wrapErr(err, "request unmarshal failed"))
+				return NewError(StatusBadRequest, "invalid payload")
+			}
+			return nil
+		}
+	}
+
+	bs, err := io.ReadAll(r.Body)
+	if tooLarge() {
+		return NewError(StatusRequestEntityTooLarge)
+	}
+	if err != nil && err != io.EOF {
+		log.Println(wrapErr(err))
+		return NewError(StatusBadRequest, "connection error")
+	}
+	if len(bs) == 0 {
+		return NewError(StatusBadRequest, "empty payload")
+	}
+	if err := c.Decode(bs, p); err != nil {
+		log.Println(wrapErr(err, "request unmarshal failed"))
+		return NewError(StatusBadRequest, "invalid payload")
+	}
+	return nil
+}
+
+// The codecs the declared response payloads can be encoded with.
+// Each payload's own codec followed by the endpoint's codecs
+func (ep *endpoint) responseCodecs() []Codec {
+	ps := []Payload{ep.responsePayload}
+	codes := make([]int, 0, len(ep.responses))
+	for code := range ep.responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		ps = append(ps, ep.responses[code])
+	}
+
+	var cs []Codec
+	seen := map[string]bool{}
+	for _, p := range ps {
+		if !hasBody(p) {
+			continue
+		}
+		for _, c := range payloadCodecs(p, ep.codecs, ep.registry) {
+			mt := mediaType(c.ContentType().String())
+			if !seen[mt] {
+				seen[mt] = true
+				cs = append(cs, c)
+			}
+		}
+	}
+	return cs
+}
 
-// }
+// Registers ep using f. Returns the route conflicts httprouter
+// panics on as errors
+func (ep *endpoint) mount(f func(string, httprouter.Handle)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = wrapErr(fmt.Errorf("%v", r))
+		}
+	}()
+	ep.handle(f)
+	return nil
+}
 
 func (ep *endpoint) handle(f func(string, httprouter.Handle)) {
+	var resCodecs []Codec
+	if len(ep.codecs) > 0 {
+		resCodecs = ep.responseCodecs()
+	}
 	f(ep.path, func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 		rd, ok := requestDataPool.Get().(*RequestData)
 		if !ok {
 			panic(wrapErr(fmt.Errorf("requestDataPool returned not *RequestData.... aaaaaaa")))
 		}
 		defer func() {
+			rd.Params = nil
+			rd.Body = nil
+			rd.QueryParams = nil
 			rd.Custom = nil
 			requestDataPool.Put(rd)
 		}()
 		rd.Custom = map[string]interface{}{}
 		rd.Params = params
 
+		rc, ok := rcPool.Get().(*RequestCtx)
+		if !ok {
+			panic(`rcpool returned something thats not a RequestCtx... aaaaaaaaa!!`)
+		}
+		defer func() {
+			rc.reset()
+			rcPool.Put(rc)
+		}()
+		rc.update(w, r)
+		rc.params = params
+		rc.ep = ep
+
+		fail := func(err error) {
+			ep.errs.write(rc, err)
+		}
 		badrequest := func(msg string) {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(StatusBadRequest)
-			w.Write([]byte(msg))
+			fail(NewError(StatusBadRequest, msg))
 		}
 
-		// Request Payload
-		if ep.requestPayload != nil {
-			pv := ep.requestPool.Get()
-			if pv == nil {
-				panic(wrapErr(fmt.Errorf("requestPayload Pool returned nil....aaaaaaaa")))
+		// Declared params
+		var msgs []string
+		for _, p := range ep.params {
+			v := paramValue(p, r, params)
+			if v == "" && p.in() != PARAM_IN_PATH {
+				if p.required() {
+					msgs = append(msgs, fmt.Sprintf("%s: required", p.name()))
+				}
+				continue
+			}
+			if err := p.check(v); err != nil {
+				if p.notFound() {
+					fail(NewError(StatusNotFound))
+					return
+				}
+				msgs = append(msgs, fmt.Sprintf("%s: %s", p.name(), err.Error()))
 			}
-			defer ep.requestPool.Put(pv)
+		}
+		if len(msgs) > 0 {
+			badrequest(strings.Join(msgs, "\n"))
+			return
+		}
 
-			v, ok := pv.(reflect.Value)
+		// Content negotiation. Only for endpoints with codecs
+		var resCodec Codec
+		if len(resCodecs) > 0 {
+			var ok bool
+			resCodec, ok = responseCodec(r.Header.Get(HeaderAccept), resCodecs)
 			if !ok {
-				panic(wrapErr(fmt.Errorf("requestBodyPool returned value of type not equal to reflect.Value")))
+				fail(NewError(StatusNotAcceptable))
+				return
 			}
-			reflect.ValueOf(rd).Elem().FieldByName("Body").Set(v)
+		}
 
-			bs, err := io.ReadAll(r.Body)
-			if err != nil {
-				// log.Println(wrapErr(err, "readall failed"))
-				if err != io.EOF {
-					log.Println(wrapErr(err))
-					badrequest("connection error")
+		// Request Payload
+		if ep.requestPool != nil {
+			var reqCodec Codec = payloadCodec{ct: ep.requestPayload.ContentType(), reg: ep.registry}
+			if len(ep.codecs) > 0 {
+				var ok bool
+				reqCodec, ok = requestCodec(r.Header.Get(HeaderContentType), payloadCodecs(ep.requestPayload, ep.codecs, ep.registry))
+				if !ok {
+					fail(NewError(StatusUnsupportedMediaType))
 					return
 				}
 			}
 
-			if len(bs) > 0 {
-				if err := rd.Body.Unmarshal(bs); err != nil {
-					log.Println(wrapErr(err, "request unmarshal failed"))
-					badrequest("invalid payload")
-					return
+			body := ep.requestPool.get()
+			defer ep.requestPool.put(body)
+			rd.Body = body
+
+			if pc, ok := body.(payloadCleaner); ok {
+				defer pc.cleanupPayload()
+			}
+			if err := ep.readBody(r, reqCodec, rd.Body); err != nil {
+				e, ok := err.(*Error)
+				if !ok {
+					log.Println(wrapErr(err))
+					e = NewError(StatusBadRequest, "invalid payload")
 				}
-			} else {
-				badrequest("empty payload")
+				fail(e)
 				return
 			}
-		}
 
-		// Query Params
-		if ep.queryPayload != nil {
-			pv := ep.queryPool.Get()
-			if pv == nil {
-				panic(wrapErr(fmt.Errorf("query pool returned empty....aaaaaaa")))
+			if ep.validate {
+				if err := validatePayload(rd.Body); err != nil {
+					ve, ok := err.(*ValidationError)
+					if !ok {
+						log.Println(wrapErr(err))
+						ve = &ValidationError{}
+						ve.Add("", "invalid payload")
+					}
+					fail(ve)
+					return
+				}
 			}
-			defer ep.queryPool.Put(pv)
+		}
 
-			v, ok := pv.(reflect.Value)
-			if !ok {
-				panic(wrapErr(fmt.Errorf("queryPool returned value of type not equal to reflect.Value")))
-			}
-			reflect.ValueOf(rd).Elem().FieldByName("QueryParams").Set(v)
+		// Query Params
+		if ep.queryPool != nil {
+			qp := ep.queryPool.get()
+			defer ep.queryPool.put(qp)
+			rd.QueryParams = qp
 
-			bs, err := json.Marshal(r.URL.Query())
-			if err != nil && err != io.EOF {
-				// This block will never run so not tested
-				log.Println(wrapErr(err, "json marshal url query failed"))
-				badrequest("invalid or missing query params")
+			values := r.URL.Query()
+			if len(values) == 0 && !ep.allowEmptyQuery {
+				badrequest("empty query params")
 				return
 			}
 
-			if len(bs) > 0 {
-				if err := rd.QueryParams.Unmarshal(bs); err != nil {
-					/*
-					  This part is not very helpful because it will be valid for any Payload
-					  type that inherently has a string->[]string structure and will fail
-					  for every other case.
-					  Ideally the structure should be verified using reflection.
-					  This Unmarshal failing will always indicate the one case above
-					*/
-					log.Println(wrapErr(err, "query unmarshal failed"))
-					badrequest("invalid or missing query params")
-					return
-				}
-				// Test if empty map
-				testm := map[string][]string{}
-				json.Unmarshal(bs, &testm)
-				if len(testm) == 0 {
-					badrequest("empty query params")
+			if err := unmarshalQuery(values, rd.QueryParams, ep.registry.json()); err != nil {
+				if e, ok := err.(*Error); ok {
+					badrequest(e.Error())
 					return
 				}
-			} else {
-				badrequest("empty query params")
+				log.Println(wrapErr(err, "query unmarshal failed"))
+				badrequest("invalid or missing query params")
 				return
 			}
 		}
 
-		rc, ok := rcPool.Get().(*RequestCtx)
-		if !ok {
-			panic(`rcpool returned something thats not a RequestCtx... aaaaaaaaa!!`)
-		}
-		defer func() {
-			rc.reset()
-			rcPool.Put(rc)
-		}()
-		rc.update(w, r)
-
 		resp, err := ep.handler(rc, rd)
 		if err != nil {
-			if err := errorHandler(rc, err); err != nil {
-				log.Println(wrapErr(err))
-			}
+			ep.errs.write(rc, err)
 			return
 		}
 
@@ -237,33 +495,45 @@ func (ep *endpoint) handle(f func(string, httprouter.Handle)) {
 			return
 		}
 
+		code := rc.status
+		if code == 0 {
+			code = StatusOK
+		}
+		if len(ep.responses) > 0 && !ep.declaresStatus(code) {
+			log.Println(wrapErr(fmt.Errorf("status %d not declared by %s %s", code, ep.method, ep.path)))
+		}
+
 		var resBody []byte
 		err = nil
-		if resp != nil {
-			resBody, err = resp.Marshal()
+		if resp != nil && bodyAllowed(code) {
+			c := Codec(payloadCodec{ct: resp.ContentType(), reg: ep.registry})
+			if resCodec != nil {
+				// resp may not be the declared ResponsePayload
+				if nc, ok := codecFor(mediaType(resCodec.ContentType().String()), payloadCodecs(resp, ep.codecs, ep.registry)); ok {
+					c = nc
+				}
+			}
+			resBody, err = c.Encode(resp)
 			if err != nil {
 				log.Println(wrapErr(err))
-				if err := errorHandler(rc, NewError(StatusInternalServerError)); err != nil {
-					log.Println(wrapErr(err))
-				}
+				ep.errs.write(rc, NewError(StatusInternalServerError))
 				return
 			}
-			rc.ResponseWriter.Header().Set("Content-Type", resp.ContentType().String())
+			rc.ResponseWriter.Header().Set("Content-Type", c.ContentType().String())
 		}
-		rc.ResponseWriter.WriteHeader(StatusOK)
+		rc.ResponseWriter.WriteHeader(code)
 		rc.ResponseWriter.Write(resBody)
 	})
 }
 
-// func (ep *endpoint) pathItem() (*openapi3.PathItem, error) {
-// 	// TODO
-// 	return &openapi3.PathItem{}, nil
-// }
-
 type EndpointPayload struct {
 	RequestPayload  Payload
 	QueryPayload    Payload
 	ResponsePayload Payload
+	// Payloads keyed by the status code they're sent with. Handlers
+	// choose amongst these using RequestCtx.SetStatus. ResponsePayload,
+	// when present, is the payload for StatusOK unless overridden here.
+	Responses map[int]Payload
 }
 
 func NewEndpointPayload(ps ...Payload) EndpointPayload {
@@ -287,7 +557,57 @@ type EndpointConfig struct {
 	Handler            Handler
 	Payload            EndpointPayload
 	ExcludeMiddlewares []string
-	method             string
+	// Run after the global and group middlewares, in order
+	Middlewares []*Middleware
+	// By default requests to endpoints with a QueryPayload are
+	// rejected when the query string is empty. Set this to allow them.
+	AllowEmptyQuery bool
+	// Extra encodings for the request and response payloads. Requests
+	// pick them using the Content-Type and Accept headers and get 415
+	// or 406 when nothing matches. The payloads' own encoding stays
+	// the default.
+	Codecs []Codec
+	// Same as Codecs using the codecs registered with App.RegisterCodec
+	ContentTypes []ContentType
+	// Limits for FormPayload and MultipartPayload bodies
+	Multipart MultipartLimits
+	// Larger request bodies are refused with StatusRequestEntityTooLarge.
+	// Zero uses AppOptions.MaxBodySize. Negative means no limit
+	MaxBodySize int64
+	// Declares the type and constraints of parameters.
+	// See PathParam, HeaderParam and CookieParam
+	Params []Param
+	// Structs whose `header` and `cookie` tags declare params as
+	// HeaderParam and CookieParam do. Bind them in the handler using
+	// RequestCtx.BindHeader and RequestCtx.BindCookie
+	Headers interface{}
+	Cookies interface{}
+	// The fields below only feed the generated OpenAPI operation.
+	// OperationID must be unique across the app.
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Deprecated  bool
+	// Unique name used to build URLs to the endpoint. See App.URL
+	Name string
+	// Nil uses the document's security requirements.
+	// An empty list marks the operation as public.
+	Security openapi3.SecurityRequirements
+	method   string
+	// Set by groups. Run after the app's global middlewares
+	groupMiddlewares []*Middleware
+	// Left out of the OpenAPI document. See App.Mount
+	undocumented bool
+	// Set for typed endpoints. See NewTypedEndpointConfig
+	requestPool payloadPool
+	queryPool   payloadPool
+	// Set for streaming endpoints. See NewStreamEndpointConfig
+	stream *streamSpec
+	// Set for SSE endpoints. See NewSSEEndpointConfig
+	sse *sseSpec
+	// Reported when the endpoint is mounted
+	err error
 }
 
 func NewEndpointConfig(path string, handler Handler) EndpointConfig {
@@ -309,6 +629,8 @@ func (ec EndpointConfig) WithHandler(h Handler) EndpointConfig {
 
 func (ec EndpointConfig) WithPayload(ep EndpointPayload) EndpointConfig {
 	ec.Payload = ep
+	ec.requestPool = nil
+	ec.queryPool = nil
 	return ec
 }
 
@@ -317,19 +639,123 @@ func (ec EndpointConfig) WithPath(p string) EndpointConfig {
 	return ec
 }
 
-func (ec *EndpointConfig) applyMiddlerwares(ms []*Middleware) {
+// Declares p as the payload sent along with the status code
+func (ec EndpointConfig) WithResponse(code int, p Payload) EndpointConfig {
+	rs := map[int]Payload{}
+	for k, v := range ec.Payload.Responses {
+		rs[k] = v
+	}
+	rs[code] = p
+	ec.Payload.Responses = rs
+	return ec
+}
+
+func (ec EndpointConfig) WithParams(ps ...Param) EndpointConfig {
+	ec.Params = append(append([]Param{}, ec.Params...), ps...)
+	return ec
+}
+
+func (ec EndpointConfig) WithCodecs(cs ...Codec) EndpointConfig {
+	ec.Codecs = append(append([]Codec{}, ec.Codecs...), cs...)
+	return ec
+}
+
+func (ec EndpointConfig) WithContentTypes(cts ...ContentType) EndpointConfig {
+	ec.ContentTypes = append(append([]ContentType{}, ec.ContentTypes...), cts...)
+	return ec
+}
+
+func (ec EndpointConfig) WithMultipartLimits(ml MultipartLimits) EndpointConfig {
+	ec.Multipart = ml
+	return ec
+}
+
+func (ec EndpointConfig) WithMaxBodySize(n int64) EndpointConfig {
+	ec.MaxBodySize = n
+	return ec
+}
+
+func (ec EndpointConfig) WithHeaders(sample interface{}) EndpointConfig {
+	ec.Headers = sample
+	return ec
+}
+
+func (ec EndpointConfig) WithCookies(sample interface{}) EndpointConfig {
+	ec.Cookies = sample
+	return ec
+}
+
+func (ec EndpointConfig) WithAllowEmptyQuery(b bool) EndpointConfig {
+	ec.AllowEmptyQuery = b
+	return ec
+}
+
+func (ec EndpointConfig) WithSummary(s string) EndpointConfig {
+	ec.Summary = s
+	return ec
+}
+
+func (ec EndpointConfig) WithDescription(d string) EndpointConfig {
+	ec.Description = d
+	return ec
+}
+
+func (ec EndpointConfig) WithTags(ts ...string) EndpointConfig {
+	ec.Tags = append(append([]string{}, ec.Tags...), ts...)
+	return ec
+}
+
+func (ec EndpointConfig) WithOperationID(id string) EndpointConfig {
+	ec.OperationID = id
+	return ec
+}
+
+func (ec EndpointConfig) WithName(name string) EndpointConfig {
+	ec.Name = name
+	return ec
+}
+
+func (ec EndpointConfig) WithDeprecated(d bool) EndpointConfig {
+	ec.Deprecated = d
+	return ec
+}
+
+func (ec EndpointConfig) WithMiddlewares(ms ...*Middleware) EndpointConfig {
+	ec.Middlewares = append(append([]*Middleware{}, ec.Middlewares...), ms...)
+	return ec
+}
+
+func (ec EndpointConfig) WithSecurity(reqs ...openapi3.SecurityRequirement) EndpointConfig {
+	ec.Security = append(openapi3.SecurityRequirements{}, reqs...)
+	return ec
+}
+
+// The middlewares ec runs. The global ones followed by its
+// groups' and then its own
+func (ec *EndpointConfig) middlewares(global []*Middleware) []*Middleware {
+	ms := append([]*Middleware{}, global...)
+	ms = append(ms, ec.groupMiddlewares...)
+	return append(ms, ec.Middlewares...)
+}
+
+// Wraps the handler with ms, skipping excluded ones.
+// Returns the middlewares applied
+func (ec *EndpointConfig) applyMiddlerwares(ms []*Middleware) []*Middleware {
 	exm := map[string]bool{}
 	for _, s := range ec.ExcludeMiddlewares {
 		exm[s] = true
 	}
 
+	applied := []*Middleware{}
 	for i := len(ms) - 1; i >= 0; i-- {
 		m := ms[i]
 		if _, ok := exm[m.ID]; ok {
 			continue
 		}
 		ec.Handler = m.Handler(ec.Handler)
+		applied = append([]*Middleware{m}, applied...)
 	}
+	return applied
 }
 
 func (ec EndpointConfig) endpoint() *endpoint {
@@ -340,7 +766,28 @@ func (ec EndpointConfig) endpoint() *endpoint {
 		requestPayload:  ec.Payload.RequestPayload,
 		queryPayload:    ec.Payload.QueryPayload,
 		responsePayload: ec.Payload.ResponsePayload,
+		responses:       ec.Payload.Responses,
 		mexclusions:     ec.ExcludeMiddlewares,
+		requestPool:     ec.requestPool,
+		queryPool:       ec.queryPool,
+		summary:         ec.Summary,
+		description:     ec.Description,
+		tags:            ec.Tags,
+		operationID:     ec.OperationID,
+		deprecated:      ec.Deprecated,
+		security:        ec.Security,
+		allowEmptyQuery: ec.AllowEmptyQuery,
+		params:          ec.Params,
+		codecs:          ec.Codecs,
+		multipart:       ec.Multipart,
+		maxBodySize:     ec.MaxBodySize,
+		contentTypes:    ec.ContentTypes,
+		headers:         ec.Headers,
+		cookies:         ec.Cookies,
+		stream:          ec.stream,
+	}
+	if hasBody(ep.requestPayload) {
+		ep.validate = validates(payloadType(ep.requestPayload))
 	}
 	ep.initPools()
 	return ep
@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	json "github.com/goccy/go-json"
@@ -177,6 +179,7 @@ func TestEndpointHandle(t *testing.T) {
 					}
 				}
 			}()
+			waitForServer(t, fmt.Sprintf("localhost:%d", port))
 			var (
 				req *http.Request
 				err error
@@ -232,3 +235,142 @@ func (trw testRW) Write(bs []byte) (int, error) {
 }
 
 func (trw testRW) WriteHeader(statusCode int) {}
+
+// Decodes straight from the body and records whether Unmarshal ran
+type testStreamPld struct {
+	testPld
+	unmarshalled bool
+}
+
+func (p *testStreamPld) Unmarshal(src []byte) error {
+	p.unmarshalled = true
+	return p.testPld.Unmarshal(src)
+}
+
+func (p *testStreamPld) UnmarshalFrom(r io.Reader) error {
+	return json.NewDecoder(r).Decode(&p.testPld)
+}
+
+// Counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func TestStreamingBody(t *testing.T) {
+	app, err := New(AppOptions{
+		Info:        *newTestApp(t).Info,
+		MaxBodySize: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshalled bool
+	handler := func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		p := rd.Body.(*testStreamPld)
+		unmarshalled = p.unmarshalled
+		return &p.testPld, nil
+	}
+	app.Post(EndpointConfig{
+		Path:    "/stream",
+		Handler: handler,
+		Payload: EndpointPayload{
+			RequestPayload:  &testStreamPld{},
+			ResponsePayload: &testPld{},
+		},
+	})
+	app.Post(EndpointConfig{
+		Path:    "/small",
+		Handler: testHandler,
+		Payload: EndpointPayload{
+			RequestPayload:  &testPld{},
+			ResponsePayload: &testPld{},
+		},
+	}.WithMaxBodySize(16))
+	app.Post(EndpointConfig{
+		Path:    "/unlimited",
+		Handler: testHandler,
+		Payload: EndpointPayload{
+			RequestPayload:  &testPld{},
+			ResponsePayload: &testPld{},
+		},
+	}.WithMaxBodySize(-1))
+
+	large := `{"key":"` + strings.Repeat("k", 100) + `","value":"v"}`
+	type tt struct {
+		name        string
+		path        string
+		body        string
+		chunked     bool
+		status      int
+		out         string
+		maxRead     int
+		noUnmarshal bool
+	}
+	tsts := []tt{
+		{
+			name:        "streamed",
+			path:        "/stream",
+			body:        `{"key":"k","value":"v"}`,
+			status:      StatusOK,
+			out:         `{"key":"k","value":"v"}`,
+			noUnmarshal: true,
+		}, {
+			name:    "content length over app limit",
+			path:    "/stream",
+			body:    large,
+			status:  StatusRequestEntityTooLarge,
+			maxRead: 0,
+		}, {
+			name:    "chunked over app limit",
+			path:    "/stream",
+			body:    large,
+			chunked: true,
+			status:  StatusRequestEntityTooLarge,
+			maxRead: 65,
+		}, {
+			name:    "endpoint limit",
+			path:    "/small",
+			body:    `{"key":"k","value":"v"}`,
+			chunked: true,
+			status:  StatusRequestEntityTooLarge,
+			maxRead: 17,
+		}, {
+			name:   "no limit",
+			path:   "/unlimited",
+			body:   large,
+			status: StatusOK,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			cr := &countingReader{Reader: strings.NewReader(tst.body)}
+			r := httptest.NewRequest(http.MethodPost, tst.path, cr)
+			r.ContentLength = int64(len(tst.body))
+			if tst.chunked {
+				r.ContentLength = -1
+			}
+			unmarshalled = false
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+			if tst.status == StatusRequestEntityTooLarge && cr.n > tst.maxRead {
+				t.Fatalf("read %d bytes. wanted at most %d", cr.n, tst.maxRead)
+			}
+			if tst.noUnmarshal && unmarshalled {
+				t.Fatalf("Unmarshal used over UnmarshalFrom")
+			}
+		})
+	}
+}
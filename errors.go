@@ -1,7 +1,11 @@
 package gate
 
 import (
+	"errors"
+	"log"
+	"net/http"
 	"strings"
+	"sync"
 )
 
 // type GateErr error
@@ -9,6 +13,11 @@ import (
 type Error struct {
 	Code    int
 	Message []string
+	// Problem details members. Only written when
+	// AppOptions.ProblemDetails is set
+	Type       string
+	Instance   string
+	Extensions map[string]interface{}
 }
 
 func (e *Error) Error() string {
@@ -27,3 +36,154 @@ func NewError(code int, message ...string) *Error {
 	}
 	return e
 }
+
+// Returns a copy of e with the problem type set to uri
+func (e Error) WithType(uri string) *Error {
+	e.Type = uri
+	return &e
+}
+
+// Returns a copy of e with the problem instance set to uri.
+// Defaults to the request's path
+func (e Error) WithInstance(uri string) *Error {
+	e.Instance = uri
+	return &e
+}
+
+// Returns a copy of e with the extension member key set to v
+func (e Error) WithExtension(key string, v interface{}) *Error {
+	ext := make(map[string]interface{}, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		ext[k] = v
+	}
+	ext[key] = v
+	e.Extensions = ext
+	return &e
+}
+
+// Writes the response for an error returned by a Handler or raised
+// by gate while checking a request. See App.SetErrorHandler
+type ErrorHandler func(rc *RequestCtx, err error)
+
+// Maps matching errors to a status code
+type errorMapping struct {
+	match func(error) bool
+	code  int
+}
+
+// How an app writes errors. Endpoints share their app's
+type errorHandling struct {
+	mu       sync.RWMutex
+	handler  ErrorHandler
+	mappings []errorMapping
+	// Write errors as application/problem+json
+	problems bool
+	// Send the messages of unknown errors to clients
+	expose bool
+}
+
+// Used by endpoints that aren't mounted on an App
+var defaultErrorHandling = &errorHandling{}
+
+func (eh *errorHandling) setHandler(h ErrorHandler) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	eh.handler = h
+}
+
+func (eh *errorHandling) addMapping(m errorMapping) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	eh.mappings = append(eh.mappings, m)
+}
+
+// Turns err into a *Error or *ValidationError. Errors nothing
+// is known about become StatusInternalServerError and are logged.
+func (eh *errorHandling) resolve(err error) error {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+	for _, m := range eh.mappings {
+		if m.match(err) {
+			return NewError(m.code, err.Error())
+		}
+	}
+
+	log.Println(wrapErr(err))
+	if eh.expose {
+		return NewError(StatusInternalServerError, err.Error())
+	}
+	return NewError(StatusInternalServerError)
+}
+
+// The status code err is answered with
+func (eh *errorHandling) status(err error) int {
+	switch e := eh.resolve(err).(type) {
+	case *ValidationError:
+		return StatusUnprocessableEntity
+	case *Error:
+		return e.Code
+	}
+	return StatusInternalServerError
+}
+
+// Writes err using the app's ErrorHandler
+func (eh *errorHandling) write(rc *RequestCtx, err error) {
+	if eh == nil {
+		eh = defaultErrorHandling
+	}
+	eh.mu.RLock()
+	h := eh.handler
+	eh.mu.RUnlock()
+	if h == nil {
+		h = eh.render
+	}
+	h(rc, err)
+}
+
+// The default ErrorHandler
+func (eh *errorHandling) render(rc *RequestCtx, err error) {
+	err = eh.resolve(err)
+	var werr error
+	if eh.problems {
+		werr = writeProblem(rc.ResponseWriter, rc.Request, err, rc.jsonCodec())
+	} else {
+		werr = writePlainError(rc.ResponseWriter, err, rc.jsonCodec())
+	}
+	if werr != nil {
+		log.Println(wrapErr(werr))
+	}
+}
+
+// The status and the messages as text
+func writePlainError(w http.ResponseWriter, err error, c Codec) error {
+	if ve, ok := err.(*ValidationError); ok {
+		bs, err := ve.encode(c)
+		if err != nil {
+			return err
+		}
+		w.Header().Set(HeaderContentType, ve.ContentType().String())
+		w.WriteHeader(StatusUnprocessableEntity)
+		_, err = w.Write(bs)
+		return err
+	}
+
+	code := StatusInternalServerError
+	if e, ok := err.(*Error); ok {
+		code = e.Code
+	}
+	if w.Header().Get(HeaderContentType) == "" {
+		w.Header().Set(HeaderContentType, "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(code)
+	_, err = w.Write([]byte(err.Error()))
+	return err
+}
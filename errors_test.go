@@ -0,0 +1,182 @@
+package gate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var errTestMissing = errors.New("missing thing")
+
+type testQuotaError struct {
+	limit int
+}
+
+func (e *testQuotaError) Error() string {
+	return fmt.Sprintf("over quota of %d", e.limit)
+}
+
+func TestErrorMapping(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	newApp := func(expose bool) *App {
+		app, err := New(AppOptions{
+			Info:         *newTestApp(t).Info,
+			ExposeErrors: expose,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.MapError(errTestMissing, StatusNotFound)
+		MapErrorType[*testQuotaError](app, StatusTooManyRequests)
+		app.Get(NewEndpointConfig("/:kind", func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			switch rc.Param("kind") {
+			case "sentinel":
+				return nil, fmt.Errorf("loading: %w", errTestMissing)
+			case "type":
+				return nil, fmt.Errorf("saving: %w", &testQuotaError{limit: 3})
+			case "gate":
+				return nil, fmt.Errorf("wrapped: %w", NewError(StatusConflict, "taken"))
+			}
+			return nil, errors.New("secret connection string")
+		}))
+		return app
+	}
+
+	type tt struct {
+		name   string
+		expose bool
+		path   string
+		status int
+		out    string
+		logged bool
+	}
+	tsts := []tt{
+		{
+			name:   "sentinel",
+			path:   "/sentinel",
+			status: StatusNotFound,
+			out:    "loading: missing thing",
+		}, {
+			name:   "type",
+			path:   "/type",
+			status: StatusTooManyRequests,
+			out:    "saving: over quota of 3",
+		}, {
+			name:   "wrapped gate error",
+			path:   "/gate",
+			status: StatusConflict,
+			out:    "taken",
+		}, {
+			name:   "unknown hidden",
+			path:   "/other",
+			status: StatusInternalServerError,
+			out:    httpStatusMessage[StatusInternalServerError],
+			logged: true,
+		}, {
+			name:   "unknown exposed",
+			expose: true,
+			path:   "/other",
+			status: StatusInternalServerError,
+			out:    "secret connection string",
+			logged: true,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			app := newApp(tst.expose)
+			logs.Reset()
+			r := httptest.NewRequest(http.MethodGet, tst.path, nil)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+			if logged := strings.Contains(logs.String(), "secret connection string"); logged != tst.logged {
+				t.Fatalf("wanted logged: %v. got: %q", tst.logged, logs.String())
+			}
+		})
+	}
+}
+
+func TestSetErrorHandler(t *testing.T) {
+	app := newTestApp(t)
+	app.MapError(errTestMissing, StatusNotFound)
+	var seen []error
+	app.SetErrorHandler(func(rc *RequestCtx, err error) {
+		seen = append(seen, err)
+		if rc.Param("id") == "default" {
+			app.DefaultErrorHandler(rc, err)
+			return
+		}
+		rc.ResponseWriter.Header().Set(HeaderContentType, ContentTypeJSON.String())
+		rc.ResponseWriter.WriteHeader(app.ErrorStatus(err))
+		rc.ResponseWriter.Write([]byte(`{"error":true}`))
+	})
+	app.Post(EndpointConfig{
+		Path: "/:id",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			return nil, errTestMissing
+		},
+		Payload: EndpointPayload{
+			RequestPayload: &testPld{},
+		},
+	}.WithParams(NewPathParam("id", ParamTypeString).WithOneOf("custom", "default")))
+
+	type tt struct {
+		name   string
+		path   string
+		body   string
+		status int
+		out    string
+	}
+	tsts := []tt{
+		{
+			name:   "handler error",
+			path:   "/custom",
+			body:   `{}`,
+			status: StatusNotFound,
+			out:    `{"error":true}`,
+		}, {
+			name:   "gate error",
+			path:   "/nope",
+			body:   `{}`,
+			status: StatusBadRequest,
+			out:    `{"error":true}`,
+		}, {
+			name:   "falls back",
+			path:   "/default",
+			body:   `{}`,
+			status: StatusNotFound,
+			out:    "missing thing",
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			seen = nil
+			r := httptest.NewRequest(http.MethodPost, tst.path, strings.NewReader(tst.body))
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+			if len(seen) != 1 {
+				t.Fatalf("error handler called %d times", len(seen))
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package gate
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	ContentTypeFORM      ContentType = MIMEApplicationForm
+	ContentTypeMULTIPART ContentType = MIMEMultipartForm
+)
+
+// Limits applied to form and multipart request bodies.
+// Zero values use the defaults below.
+type MultipartLimits struct {
+	// Largest accepted file. Defaults to 32 MiB
+	MaxFileSize int64
+	// Largest accepted body. Defaults to 64 MiB
+	MaxTotalSize int64
+	// Files are kept in memory until they add up to this many bytes.
+	// The rest are written to temporary files. Defaults to 8 MiB
+	MaxMemory int64
+}
+
+const (
+	defaultMaxFileSize  = 32 << 20
+	defaultMaxTotalSize = 64 << 20
+	defaultMaxMemory    = 8 << 20
+)
+
+func (ml MultipartLimits) withDefaults() MultipartLimits {
+	if ml.MaxFileSize <= 0 {
+		ml.MaxFileSize = defaultMaxFileSize
+	}
+	if ml.MaxTotalSize <= 0 {
+		ml.MaxTotalSize = defaultMaxTotalSize
+	}
+	if ml.MaxMemory <= 0 {
+		ml.MaxMemory = defaultMaxMemory
+	}
+	return ml
+}
+
+// An uploaded file. Small files are held in memory and larger ones
+// in a temporary file that's removed once the handler returns.
+type File struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+	content  []byte
+	tmpPath  string
+}
+
+var fileType = reflect.TypeOf(File{})
+
+// Returns a reader over the file's content. Close it when done.
+func (f *File) Open() (io.ReadCloser, error) {
+	if f.tmpPath == "" {
+		return io.NopCloser(bytes.NewReader(f.content)), nil
+	}
+	file, err := os.Open(f.tmpPath)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return file, nil
+}
+
+// The Content-Type the client sent for the file
+func (f *File) ContentType() string {
+	return f.Header.Get(HeaderContentType)
+}
+
+func (f *File) remove() {
+	if f.tmpPath != "" {
+		os.Remove(f.tmpPath)
+		f.tmpPath = ""
+	}
+	f.content = nil
+}
+
+// Whether a field of type typ is bound from uploaded files
+func isFileField(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Ptr && typ.Elem() == fileType
+}
+
+// Binds an application/x-www-form-urlencoded body into Value using
+// `form` tags. See tagField for the supported tags.
+type FormPayload[T any] struct {
+	Value T `gate:"inline"`
+}
+
+func (fp FormPayload[T]) Marshal() ([]byte, error) {
+	values, err := encodeValues(&fp.Value, "form")
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (fp *FormPayload[T]) Unmarshal(src []byte) error {
+	values, err := url.ParseQuery(string(src))
+	if err != nil {
+		return NewError(StatusBadRequest, "invalid form body")
+	}
+	return decodeValues(values, &fp.Value, "form")
+}
+
+func (FormPayload[T]) ContentType() ContentType {
+	return ContentTypeFORM
+}
+
+func (fp *FormPayload[T]) decodeRequest(r *http.Request, ep *endpoint) error {
+	if mt := mediaType(r.Header.Get(HeaderContentType)); mt != MIMEApplicationForm {
+		return NewError(StatusUnsupportedMediaType)
+	}
+	limit := ep.multipart.withDefaults().MaxTotalSize
+	bs, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return NewError(StatusBadRequest, "connection error")
+	}
+	if int64(len(bs)) > limit {
+		return NewError(StatusRequestEntityTooLarge)
+	}
+	return fp.Unmarshal(bs)
+}
+
+func (fp FormPayload[T]) schema(sg *schemaGen) (*openapi3.SchemaRef, error) {
+	return sg.formSchema(reflect.TypeOf(fp.Value))
+}
+
+// Binds a multipart/form-data body into Value using `form` tags.
+// Fields of type *File or []*File receive the uploaded files. Files
+// holds every uploaded file by field name, declared or not.
+//
+// The whole body is read before the handler runs. Files are kept
+// in memory up to MultipartLimits.MaxMemory and in temporary files
+// past that. Use MultipartStream to read files as they arrive.
+// Limits are configured using EndpointConfig.Multipart and exceeding
+// them answers StatusRequestEntityTooLarge.
+type MultipartPayload[T any] struct {
+	Value T                  `gate:"inline"`
+	Files map[string][]*File `json:"-"`
+}
+
+func (MultipartPayload[T]) Marshal() ([]byte, error) {
+	return nil, wrapErr(fmt.Errorf("MultipartPayload can't be marshalled"))
+}
+
+func (*MultipartPayload[T]) Unmarshal([]byte) error {
+	return wrapErr(fmt.Errorf("MultipartPayload needs the request's boundary to unmarshal"))
+}
+
+func (MultipartPayload[T]) ContentType() ContentType {
+	return ContentTypeMULTIPART
+}
+
+func (mp MultipartPayload[T]) schema(sg *schemaGen) (*openapi3.SchemaRef, error) {
+	return sg.formSchema(reflect.TypeOf(mp.Value))
+}
+
+// Removes the temporary files once the request is done
+func (mp *MultipartPayload[T]) cleanupPayload() {
+	for _, fs := range mp.Files {
+		for _, f := range fs {
+			f.remove()
+		}
+	}
+	mp.Files = nil
+}
+
+func (mp *MultipartPayload[T]) decodeRequest(r *http.Request, ep *endpoint) error {
+	if mt := mediaType(r.Header.Get(HeaderContentType)); mt != MIMEMultipartForm {
+		return NewError(StatusUnsupportedMediaType)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return NewError(StatusBadRequest, "invalid multipart body")
+	}
+
+	if err := mp.readParts(mr, ep.multipart.withDefaults()); err != nil {
+		mp.cleanupPayload()
+		return err
+	}
+	if err := mp.bindFiles(); err != nil {
+		mp.cleanupPayload()
+		return err
+	}
+	return nil
+}
+
+func (mp *MultipartPayload[T]) readParts(mr *multipart.Reader, limits MultipartLimits) error {
+	values := url.Values{}
+	mp.Files = map[string][]*File{}
+	var total, memory int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return NewError(StatusBadRequest, "invalid multipart body")
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			left := limits.MaxTotalSize - total
+			bs, err := io.ReadAll(io.LimitReader(part, left+1))
+			part.Close()
+			if err != nil {
+				return NewError(StatusBadRequest, "invalid multipart body")
+			}
+			if total += int64(len(bs)); total > limits.MaxTotalSize {
+				return NewError(StatusRequestEntityTooLarge)
+			}
+			values.Add(name, string(bs))
+			continue
+		}
+
+		max := limits.MaxFileSize
+		if left := limits.MaxTotalSize - total; left < max {
+			max = left
+		}
+		f, err := readFile(part, max, limits.MaxMemory-memory)
+		part.Close()
+		if err != nil {
+			return err
+		}
+		if f.tmpPath == "" {
+			memory += f.Size
+		}
+		total += f.Size
+		mp.Files[name] = append(mp.Files[name], f)
+	}
+
+	return decodeValues(values, &mp.Value, "form")
+}
+
+// Reads the file in part. At most memLeft bytes are kept in memory.
+// Files larger than max are rejected.
+func readFile(part *multipart.Part, max, memLeft int64) (*File, error) {
+	f := &File{Filename: part.FileName(), Header: part.Header}
+	tooLarge := NewError(StatusRequestEntityTooLarge, fmt.Sprintf("%s: file too large", part.FormName()))
+
+	inMemory := max
+	if memLeft < inMemory {
+		inMemory = memLeft
+	}
+	if inMemory < 0 {
+		inMemory = 0
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, part, inMemory+1)
+	if err == io.EOF {
+		f.content, f.Size = buf.Bytes(), n
+		return f, nil
+	}
+	if err != nil {
+		return nil, NewError(StatusBadRequest, "invalid multipart body")
+	}
+	if n > max {
+		return nil, tooLarge
+	}
+
+	// Doesn't fit in memory. Spill to disk.
+	tmp, err := os.CreateTemp("", "gate-upload-*")
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	f.tmpPath = tmp.Name()
+	written, err := io.Copy(tmp, io.MultiReader(&buf, io.LimitReader(part, max-n+1)))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		f.remove()
+		return nil, NewError(StatusBadRequest, "invalid multipart body")
+	}
+	if written > max {
+		f.remove()
+		return nil, tooLarge
+	}
+	f.Size = written
+	return f, nil
+}
+
+// Moves files into the *File and []*File fields of Value
+func (mp *MultipartPayload[T]) bindFiles() error {
+	rv := reflect.ValueOf(&mp.Value).Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var msgs []string
+	for _, f := range tagFields(rv.Type(), "form") {
+		if !isFileField(f.typ) {
+			continue
+		}
+		files := mp.Files[f.name]
+		if len(files) == 0 {
+			if f.required {
+				msgs = append(msgs, fmt.Sprintf("%s: required", f.name))
+			}
+			continue
+		}
+
+		fv := fieldByIndex(rv, f.index, true)
+		if fv.Kind() == reflect.Slice {
+			fv.Set(reflect.ValueOf(files))
+		} else {
+			if len(files) > 1 {
+				msgs = append(msgs, fmt.Sprintf("%s: wanted a single file. got %d", f.name, len(files)))
+				continue
+			}
+			fv.Set(reflect.ValueOf(files[0]))
+		}
+	}
+	if len(msgs) > 0 {
+		return NewError(StatusBadRequest, msgs...)
+	}
+	return nil
+}
+
+// Hands a multipart/form-data body to the handler unread. Parts are
+// read one at a time using Next so uploads are never buffered. T only
+// documents the parts using `form` tags, nothing is bound into it.
+// MaxFileSize and MaxTotalSize of EndpointConfig.Multipart apply as
+// the parts are read.
+type MultipartStream[T any] struct {
+	mr      *multipart.Reader
+	counter *partCounter
+	part    *Part
+}
+
+// A part of a multipart body. Reading past a limit fails with an
+// *Error of StatusRequestEntityTooLarge.
+type Part struct {
+	*multipart.Part
+	stream *partCounter
+	read   int64
+}
+
+// Counts the bytes read across the parts of a MultipartStream
+type partCounter struct {
+	limits MultipartLimits
+	total  int64
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	n, err := p.Part.Read(b)
+	p.read += int64(n)
+	p.stream.total += int64(n)
+	if p.FileName() != "" && p.read > p.stream.limits.MaxFileSize {
+		return n, NewError(StatusRequestEntityTooLarge, fmt.Sprintf("%s: file too large", p.FormName()))
+	}
+	if p.stream.total > p.stream.limits.MaxTotalSize {
+		return n, NewError(StatusRequestEntityTooLarge)
+	}
+	return n, err
+}
+
+// Returns the next part of the body. Unread bytes of the previous
+// part are skipped. Returns io.EOF once every part is read.
+func (ms *MultipartStream[T]) Next() (*Part, error) {
+	if ms.mr == nil {
+		return nil, wrapErr(fmt.Errorf("no multipart body"))
+	}
+	if ms.part != nil {
+		if _, err := io.Copy(io.Discard, ms.part); err != nil {
+			return nil, err
+		}
+		ms.part.Close()
+		ms.part = nil
+	}
+	for {
+		p, err := ms.mr.NextPart()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, NewError(StatusBadRequest, "invalid multipart body")
+		}
+		if p.FormName() == "" {
+			p.Close()
+			continue
+		}
+		ms.part = &Part{Part: p, stream: ms.counter}
+		return ms.part, nil
+	}
+}
+
+func (MultipartStream[T]) Marshal() ([]byte, error) {
+	return nil, wrapErr(fmt.Errorf("MultipartStream can't be marshalled"))
+}
+
+func (*MultipartStream[T]) Unmarshal([]byte) error {
+	return wrapErr(fmt.Errorf("MultipartStream reads the request itself"))
+}
+
+func (MultipartStream[T]) ContentType() ContentType {
+	return ContentTypeMULTIPART
+}
+
+func (MultipartStream[T]) schema(sg *schemaGen) (*openapi3.SchemaRef, error) {
+	return sg.formSchema(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func (ms *MultipartStream[T]) decodeRequest(r *http.Request, ep *endpoint) error {
+	if mt := mediaType(r.Header.Get(HeaderContentType)); mt != MIMEMultipartForm {
+		return NewError(StatusUnsupportedMediaType)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return NewError(StatusBadRequest, "invalid multipart body")
+	}
+	ms.mr, ms.part = mr, nil
+	ms.counter = &partCounter{limits: ep.multipart.withDefaults()}
+	return nil
+}
+
+// Forgets the request once it's done
+func (ms *MultipartStream[T]) cleanupPayload() {
+	if ms.part != nil {
+		ms.part.Close()
+	}
+	ms.mr, ms.counter, ms.part = nil, nil, nil
+}
+
+// Builds the schema of a form body bound into typ
+func (sg *schemaGen) formSchema(typ reflect.Type) (*openapi3.SchemaRef, error) {
+	s := openapi3.NewObjectSchema()
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return openapi3.NewSchemaRef("", s), nil
+	}
+
+	for _, f := range tagFields(typ, "form") {
+		var sr *openapi3.SchemaRef
+		switch {
+		case isFileField(f.typ):
+			fs := openapi3.NewStringSchema().WithFormat("binary")
+			if f.typ.Kind() == reflect.Slice {
+				fs = openapi3.NewArraySchema().WithItems(fs)
+			}
+			sr = openapi3.NewSchemaRef("", fs)
+		default:
+			var err error
+			sr, err = sg.schemaFromType(f.typ)
+			if err != nil {
+				return nil, wrapErr(err, f.name)
+			}
+			if sr.Ref == "" && f.hasDef {
+				sr.Value.Default = queryDefault(f.typ, f)
+			}
+		}
+		s.WithPropertyRef(f.name, sr)
+		if f.required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return openapi3.NewSchemaRef("", s), nil
+}
+
+// The reverse of decodeValues
+func encodeValues(src interface{}, key string) (url.Values, error) {
+	rv := reflect.ValueOf(src)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, wrapErr(fmt.Errorf("%s source must be a pointer to a struct. got %T", key, src))
+	}
+	rv = rv.Elem()
+
+	values := url.Values{}
+	for _, f := range tagFields(rv.Type(), key) {
+		if isFileField(f.typ) {
+			continue
+		}
+		fv := fieldByIndex(rv, f.index, false)
+		if !fv.IsValid() {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+			for i := 0; i < fv.Len(); i++ {
+				values.Add(f.name, formatValue(fv.Index(i), f.format))
+			}
+			continue
+		}
+		values.Add(f.name, formatValue(fv, f.format))
+	}
+	return values, nil
+}
+
+func formatValue(fv reflect.Value, format string) string {
+	switch v := fv.Interface().(type) {
+	case time.Time:
+		if format == "" {
+			format = time.RFC3339
+		}
+		return v.Format(format)
+	case time.Duration:
+		return v.String()
+	case encoding.TextMarshaler:
+		bs, err := v.MarshalText()
+		if err == nil {
+			return string(bs)
+		}
+	}
+	return fmt.Sprint(fv.Interface())
+}
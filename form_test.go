@@ -0,0 +1,363 @@
+package gate
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testSignupForm struct {
+	Name  string   `form:"name,required" validate:"min=2"`
+	Age   int      `form:"age" default:"18"`
+	Langs []string `form:"lang"`
+}
+
+type testUploadForm struct {
+	Title  string  `form:"title,required"`
+	Avatar *File   `form:"avatar,required"`
+	Docs   []*File `form:"docs"`
+}
+
+func TestFormPayload(t *testing.T) {
+	app := newTestApp(t)
+	Post(app, "/signup", func(rc *RequestCtx, req *FormPayload[testSignupForm], _ *NoPayload) (*FormPayload[testSignupForm], error) {
+		return req, nil
+	})
+
+	type tt struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		out         string
+	}
+	tsts := []tt{
+		{
+			name:        "valid",
+			contentType: MIMEApplicationForm,
+			body:        "name=ann&lang=go&lang=c",
+			status:      StatusOK,
+			out:         "age=18&lang=go&lang=c&name=ann",
+		}, {
+			name:        "missing field",
+			contentType: MIMEApplicationForm,
+			body:        "age=3",
+			status:      StatusBadRequest,
+		}, {
+			name:        "invalid field",
+			contentType: MIMEApplicationForm,
+			body:        "name=a",
+			status:      StatusUnprocessableEntity,
+		}, {
+			name:        "not a form",
+			contentType: MIMEApplicationJSON,
+			body:        `{"name":"ann"}`,
+			status:      StatusUnsupportedMediaType,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(tst.body))
+			r.Header.Set(HeaderContentType, tst.contentType)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := doc.Paths["/signup"].Post.RequestBody.Value.Content.Get(MIMEApplicationForm)
+	if mt == nil || mt.Schema.Value.Properties["lang"] == nil || mt.Schema.Value.Required[0] != "name" {
+		t.Fatalf("form body not documented")
+	}
+}
+
+type testPart struct {
+	name     string
+	filename string
+	content  string
+}
+
+func multipartBody(t *testing.T, parts []testPart) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		var (
+			w   io.Writer
+			err error
+		)
+		if p.filename != "" {
+			w, err = mw.CreateFormFile(p.name, p.filename)
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(p.content))
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, mw.FormDataContentType()
+}
+
+func TestMultipartPayload(t *testing.T) {
+	app := newTestApp(t)
+	var spilled []string
+	app.Post(NewTypedEndpointConfig(
+		"/upload",
+		func(rc *RequestCtx, req *MultipartPayload[testUploadForm], _ *NoPayload) (*String, error) {
+			out := req.Value.Title
+			for _, f := range append([]*File{req.Value.Avatar}, req.Value.Docs...) {
+				rdr, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				bs, _ := io.ReadAll(rdr)
+				rdr.Close()
+				out += " " + f.Filename + ":" + string(bs)
+				if f.tmpPath != "" {
+					spilled = append(spilled, f.tmpPath)
+				}
+			}
+			for name, fs := range req.Files {
+				if name != "avatar" && name != "docs" {
+					out += " extra:" + fs[0].Filename
+				}
+			}
+			return NewString(out), nil
+		},
+	).WithMultipartLimits(MultipartLimits{
+		MaxFileSize:  10,
+		MaxTotalSize: 30,
+		MaxMemory:    6,
+	}))
+
+	type tt struct {
+		name   string
+		parts  []testPart
+		status int
+		out    string
+	}
+	tsts := []tt{
+		{
+			name: "valid",
+			parts: []testPart{
+				{name: "title", content: "hi"},
+				{name: "avatar", filename: "a.png", content: "png"},
+				{name: "docs", filename: "b.txt", content: "bbbbbbb"},
+				{name: "other", filename: "c.txt", content: "c"},
+			},
+			status: StatusOK,
+			out:    `"hi a.png:png b.txt:bbbbbbb extra:c.txt"`,
+		}, {
+			name: "missing file",
+			parts: []testPart{
+				{name: "title", content: "hi"},
+			},
+			status: StatusBadRequest,
+		}, {
+			name: "file too large",
+			parts: []testPart{
+				{name: "title", content: "hi"},
+				{name: "avatar", filename: "a.png", content: "0123456789a"},
+			},
+			status: StatusRequestEntityTooLarge,
+		}, {
+			name: "body too large",
+			parts: []testPart{
+				{name: "title", content: "hi"},
+				{name: "avatar", filename: "a.png", content: "0123456789"},
+				{name: "docs", filename: "b.txt", content: "0123456789"},
+				{name: "docs", filename: "c.txt", content: "0123456789"},
+			},
+			status: StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			body, ct := multipartBody(t, tst.parts)
+			r := httptest.NewRequest(http.MethodPost, "/upload", body)
+			r.Header.Set(HeaderContentType, ct)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	if len(spilled) == 0 {
+		t.Fatalf("no file was written to disk")
+	}
+	for _, p := range spilled {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("temporary file %s not removed", p)
+		}
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := doc.Paths["/upload"].Post.RequestBody.Value.Content.Get(MIMEMultipartForm)
+	if mt == nil {
+		t.Fatalf("multipart body not documented")
+	}
+	props := mt.Schema.Value.Properties
+	if props["avatar"].Value.Format != "binary" || props["docs"].Value.Items.Value.Format != "binary" {
+		t.Fatalf("files not documented as binary")
+	}
+}
+
+func TestMultipartStream(t *testing.T) {
+	app := newTestApp(t)
+	seen := make(chan string, 8)
+	app.Post(NewTypedEndpointConfig(
+		"/upload",
+		func(rc *RequestCtx, req *MultipartStream[testUploadForm], _ *NoPayload) (*String, error) {
+			var out []string
+			for {
+				p, err := req.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				seen <- p.FormName()
+				if p.FormName() == "skipped" {
+					continue
+				}
+				bs, err := io.ReadAll(p)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, p.FormName()+":"+p.FileName()+":"+string(bs))
+			}
+			return NewString(strings.Join(out, " ")), nil
+		},
+	).WithMultipartLimits(MultipartLimits{
+		MaxFileSize:  10,
+		MaxTotalSize: 30,
+	}))
+
+	type tt struct {
+		name   string
+		parts  []testPart
+		status int
+		out    string
+	}
+	tsts := []tt{
+		{
+			name: "valid",
+			parts: []testPart{
+				{name: "title", content: "hi"},
+				{name: "skipped", filename: "s.txt", content: "ssss"},
+				{name: "avatar", filename: "a.png", content: "png"},
+			},
+			status: StatusOK,
+			out:    `"title::hi avatar:a.png:png"`,
+		}, {
+			name: "file too large",
+			parts: []testPart{
+				{name: "avatar", filename: "a.png", content: "0123456789a"},
+			},
+			status: StatusRequestEntityTooLarge,
+		}, {
+			name: "body too large",
+			parts: []testPart{
+				{name: "a", filename: "a.txt", content: "0123456789"},
+				{name: "b", filename: "b.txt", content: "0123456789"},
+				{name: "c", filename: "c.txt", content: "0123456789"},
+				{name: "d", content: "0"},
+			},
+			status: StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			body, ct := multipartBody(t, tst.parts)
+			r := httptest.NewRequest(http.MethodPost, "/upload", body)
+			r.Header.Set(HeaderContentType, ct)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+			for len(seen) > 0 {
+				<-seen
+			}
+		})
+	}
+
+	t.Run("streamed", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		r := httptest.NewRequest(http.MethodPost, "/upload", pr)
+		r.Header.Set(HeaderContentType, mw.FormDataContentType())
+		rw := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			app.ServeHTTP(rw, r)
+			close(done)
+		}()
+
+		w, _ := mw.CreateFormFile("avatar", "a.png")
+		w.Write([]byte("png"))
+		mw.CreateFormField("title")
+		// The handler gets the first part before the body is complete
+		if name := <-seen; name != "avatar" {
+			t.Fatalf("wanted avatar. got: %s", name)
+		}
+		mw.Close()
+		pw.Close()
+		<-done
+		if rw.Body.String() != `"avatar:a.png:png title::"` {
+			t.Fatalf("got: %s", rw.Body.String())
+		}
+	})
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := doc.Paths["/upload"].Post.RequestBody.Value.Content.Get(MIMEMultipartForm)
+	if mt == nil || mt.Schema.Value.Properties["avatar"].Value.Format != "binary" {
+		t.Fatalf("multipart body not documented")
+	}
+}
+
+func TestEncodeValues(t *testing.T) {
+	v := testSignupForm{Name: "ann", Age: 3, Langs: []string{"go"}}
+	values, err := encodeValues(&v, "form")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"name": {"ann"}, "age": {"3"}, "lang": {"go"}}
+	if values.Encode() != want.Encode() {
+		t.Fatalf("wanted: %s. got: %s", want.Encode(), values.Encode())
+	}
+}
@@ -4,14 +4,17 @@ go 1.18
 
 require (
 	github.com/getkin/kin-openapi v0.92.0
+	github.com/ghodss/yaml v1.0.0
 	github.com/goccy/go-json v0.9.5
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	google.golang.org/protobuf v1.28.1
 )
 
 require (
-	github.com/ghodss/yaml v1.0.0 // indirect
 	github.com/go-openapi/jsonpointer v0.19.5 // indirect
 	github.com/go-openapi/swag v0.19.5 // indirect
 	github.com/mailru/easyjson v0.0.0-20190626092158-b2ccc519800e // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
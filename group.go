@@ -0,0 +1,154 @@
+package gate
+
+import (
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Registers endpoints under a shared path prefix. Its middlewares
+// run inside the app's global ones for every endpoint of the group
+// and its subgroups. Create one using App.Group.
+type Group struct {
+	app         *App
+	prefix      string
+	middlewares []*Middleware
+	tags        []string
+	security    openapi3.SecurityRequirements
+}
+
+// Creates a Group of endpoints whose paths start with prefix.
+// ms run for every endpoint in the group, after the app's global
+// middlewares and in the order given.
+func (app *App) Group(prefix string, ms ...*Middleware) *Group {
+	return &Group{
+		app:         app,
+		prefix:      cleanPrefix(prefix),
+		middlewares: ms,
+	}
+}
+
+// "/v1/" and "v1" both become "/v1". "/" becomes ""
+func cleanPrefix(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// Creates a Group nested in g. Its prefix is appended to g's and its
+// middlewares run after g's. Tags and security are inherited.
+func (g *Group) Group(prefix string, ms ...*Middleware) *Group {
+	ng := g.copy()
+	ng.prefix += cleanPrefix(prefix)
+	ng.middlewares = append(ng.middlewares, ms...)
+	return ng
+}
+
+func (g *Group) copy() *Group {
+	ng := *g
+	ng.middlewares = append([]*Middleware{}, g.middlewares...)
+	ng.tags = append([]string{}, g.tags...)
+	if g.security != nil {
+		ng.security = append(openapi3.SecurityRequirements{}, g.security...)
+	}
+	return &ng
+}
+
+// Returns a copy of g adding ts to the tags of every operation
+func (g *Group) WithTags(ts ...string) *Group {
+	ng := g.copy()
+	ng.tags = append(ng.tags, ts...)
+	return ng
+}
+
+// Returns a copy of g whose operations default to the given security
+// requirements. Endpoints setting EndpointConfig.Security keep theirs.
+func (g *Group) WithSecurity(reqs ...openapi3.SecurityRequirement) *Group {
+	ng := g.copy()
+	ng.security = append(openapi3.SecurityRequirements{}, reqs...)
+	return ng
+}
+
+// The path prefix of the group
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Applies the group's prefix, middlewares and defaults to ec
+func (g *Group) apply(ec EndpointConfig) EndpointConfig {
+	ec.Path = g.prefix + ec.Path
+	if ec.Path == "" {
+		ec.Path = "/"
+	}
+	ec.groupMiddlewares = append(append([]*Middleware{}, g.middlewares...), ec.groupMiddlewares...)
+
+	tags := append([]string{}, g.tags...)
+	for _, t := range ec.Tags {
+		if !containsString(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) > 0 {
+		ec.Tags = tags
+	}
+	if ec.Security == nil && g.security != nil {
+		ec.Security = g.security
+	}
+	return ec
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Add a GET endpoint to the group
+func (g *Group) Get(ec EndpointConfig) {
+	g.app.Get(g.apply(ec))
+}
+
+// Add a POST endpoint to the group
+func (g *Group) Post(ec EndpointConfig) {
+	g.app.Post(g.apply(ec))
+}
+
+// Add a DELETE endpoint to the group
+func (g *Group) Delete(ec EndpointConfig) {
+	g.app.Delete(g.apply(ec))
+}
+
+// Add a PUT endpoint to the group
+func (g *Group) Put(ec EndpointConfig) {
+	g.app.Put(g.apply(ec))
+}
+
+// Add a PATCH endpoint to the group
+func (g *Group) Patch(ec EndpointConfig) {
+	g.app.Patch(g.apply(ec))
+}
+
+// Add an OPTIONS endpoint to the group
+func (g *Group) Options(ec EndpointConfig) {
+	g.app.Options(g.apply(ec))
+}
+
+// Add a HEAD endpoint to the group
+func (g *Group) Head(ec EndpointConfig) {
+	g.app.Head(g.apply(ec))
+}
+
+// Add an SSE endpoint to the group. See App.SSE
+func (g *Group) SSE(ec EndpointConfig) {
+	g.app.SSE(g.apply(ec))
+}
+
+// Add a WebSocket endpoint to the group. See App.WebSocket
+func (g *Group) WebSocket(path string, h WebSocketHandler) {
+	g.app.Get(g.apply(g.app.websocketConfig(path, h)))
+}
@@ -0,0 +1,112 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func testOrderMiddleware(id string) *Middleware {
+	return &Middleware{
+		ID: id,
+		Handler: func(h Handler) Handler {
+			return func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+				rc.ResponseWriter.Header().Add("X-Order", id)
+				return h(rc, rd)
+			}
+		},
+	}
+}
+
+func TestGroup(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(testOrderMiddleware("global")); err != nil {
+		t.Fatal(err)
+	}
+	app.AddSecurityScheme("bearer", openapi3.NewJWTSecurityScheme())
+	bearer := openapi3.NewSecurityRequirement().Authenticate("bearer")
+
+	v1 := app.Group("/v1/", testOrderMiddleware("v1")).WithTags("v1")
+	admin := v1.Group("admin", testOrderMiddleware("admin")).
+		WithTags("admin").
+		WithSecurity(bearer)
+	v1.Get(NewEndpointConfig("/status", testHandler))
+	admin.Get(NewEndpointConfig("/users/:id", testHandler).WithTags("users", "admin"))
+	admin.Post(NewEndpointConfig("/login", testHandler).WithSecurity())
+	del := NewEndpointConfig("/users/:id", testHandler)
+	del.ExcludeMiddlewares = []string{"v1"}
+	admin.Delete(del)
+
+	type tt struct {
+		name   string
+		method string
+		path   string
+		order  string
+	}
+	tsts := []tt{
+		{
+			name:   "group",
+			method: http.MethodGet,
+			path:   "/v1/status",
+			order:  "global,v1",
+		}, {
+			name:   "nested",
+			method: http.MethodGet,
+			path:   "/v1/admin/users/1",
+			order:  "global,v1,admin",
+		}, {
+			name:   "excluded",
+			method: http.MethodDelete,
+			path:   "/v1/admin/users/1",
+			order:  "global,admin",
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(tst.method, tst.path, nil)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != StatusOK {
+				t.Fatalf("wanted: %d. got: %d", StatusOK, rw.Code)
+			}
+			if o := strings.Join(rw.Header().Values("X-Order"), ","); o != tst.order {
+				t.Fatalf("wanted: %s. got: %s", tst.order, o)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Components.SecuritySchemes["bearer"] == nil {
+		t.Fatalf("security scheme missing")
+	}
+	if op := doc.Paths["/v1/status"].Get; strings.Join(op.Tags, ",") != "v1" || op.Security != nil {
+		t.Fatalf("status: tags %v. security %v", op.Tags, op.Security)
+	}
+	op := doc.Paths["/v1/admin/users/{id}"].Get
+	if strings.Join(op.Tags, ",") != "v1,admin,users" {
+		t.Fatalf("wrong tags: %v", op.Tags)
+	}
+	if op.Security == nil || len(*op.Security) != 1 || (*op.Security)[0]["bearer"] == nil {
+		t.Fatalf("wrong security: %v", op.Security)
+	}
+	if op := doc.Paths["/v1/admin/login"].Post; op.Security == nil || len(*op.Security) != 0 {
+		t.Fatalf("login should be public: %v", op.Security)
+	}
+}
+
+func TestGroupDuplicateMiddleware(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(testOrderMiddleware("auth")); err != nil {
+		t.Fatal(err)
+	}
+	app.Group("/admin", testOrderMiddleware("auth")).Get(NewEndpointConfig("/", testHandler))
+	if _, err := app.OpenAPI(); err == nil || !strings.Contains(err.Error(), `"auth"`) {
+		t.Fatalf("wanted duplicate middleware error. got: %v", err)
+	}
+}
@@ -445,12 +445,25 @@ func (c ContentType) String() string {
 }
 
 const (
-	ContentTypeJSON  ContentType = "application/json"
-	ContentTypePROTO ContentType = "application/vnd.google.protobuf"
-	ContentTypeHTML  ContentType = "text/html; charset=utf-8"
-	ContentTypeTEXT  ContentType = "text/plain; charset=utf-8"
+	ContentTypeJSON    ContentType = "application/json"
+	ContentTypePROTO   ContentType = "application/vnd.google.protobuf"
+	ContentTypeXML     ContentType = "application/xml"
+	ContentTypeMSGPACK ContentType = "application/msgpack"
+	ContentTypeHTML    ContentType = "text/html; charset=utf-8"
+	ContentTypeTEXT    ContentType = "text/plain; charset=utf-8"
 )
 
+// Responses with these codes must not carry a body
+func bodyAllowed(code int) bool {
+	switch {
+	case code >= 100 && code < 200,
+		code == StatusNoContent,
+		code == StatusNotModified:
+		return false
+	}
+	return true
+}
+
 func wrapErr(err error, msgs ...string) error {
 	pc := make([]uintptr, 15)
 	n := runtime.Callers(2, pc)
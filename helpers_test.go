@@ -7,7 +7,7 @@ import (
 
 func TestWrapErr(t *testing.T) {
 	p := "test error"
-	o := fmt.Sprintf("github.com/enalk-com/gate.TestWrapErr -> %s", p)
+	o := fmt.Sprintf("github.com/daimaou92/gate.TestWrapErr -> %s", p)
 	v := wrapErr(fmt.Errorf(p)).Error()
 	if o != v {
 		t.Fatalf("wanted: %s. got: %s", o, v)
@@ -1,5 +1,11 @@
 package gate
 
+import (
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
 /*
 	Middlewares will be called as 'Apply'ed
 	i.e. The first middleware to be added via a call to App.Apply()
@@ -10,6 +16,11 @@ package gate
 type Middleware struct {
 	ID      string
 	Handler func(Handler) Handler
+	// Optional. Called with the operation of every endpoint the
+	// middleware wraps, after the endpoint's own documentation
+	// is generated. Use it to add security requirements, extra
+	// responses and the like.
+	Document func(*openapi3.Operation)
 }
 
 func (m Middleware) valid(app *App) bool {
@@ -19,3 +30,18 @@ func (m Middleware) valid(app *App) bool {
 
 	return true
 }
+
+// Checks that the middlewares an endpoint runs have distinct IDs
+func checkMiddlewareIDs(ms []*Middleware) error {
+	seen := map[string]bool{}
+	for _, m := range ms {
+		if m == nil || m.ID == "" || m.Handler == nil {
+			return wrapErr(fmt.Errorf("invalid middleware"))
+		}
+		if seen[m.ID] {
+			return wrapErr(fmt.Errorf("middleware %q used more than once", m.ID))
+		}
+		seen[m.ID] = true
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func TestEndpointMiddlewares(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(testOrderMiddleware("global")); err != nil {
+		t.Fatal(err)
+	}
+	app.AddSecurityScheme("key", openapi3.NewSecurityScheme().WithType("apiKey").WithIn("header").WithName("X-Key"))
+	auth := testOrderMiddleware("auth")
+	auth.Document = func(op *openapi3.Operation) {
+		sec := openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate("key"))
+		op.Security = sec
+		op.Responses["401"] = &openapi3.ResponseRef{
+			Value: openapi3.NewResponse().WithDescription("Missing key"),
+		}
+	}
+
+	app.Group("/g", testOrderMiddleware("group")).Get(
+		NewEndpointConfig("/secret", testHandler).
+			WithMiddlewares(auth, testOrderMiddleware("last")),
+	)
+	skipped := NewEndpointConfig("/skipped", testHandler).WithMiddlewares(auth)
+	skipped.ExcludeMiddlewares = []string{"auth"}
+	app.Get(skipped)
+
+	type tt struct {
+		name  string
+		path  string
+		order string
+	}
+	tsts := []tt{
+		{
+			name:  "after global and group",
+			path:  "/g/secret",
+			order: "global,group,auth,last",
+		}, {
+			name:  "excluded",
+			path:  "/skipped",
+			order: "global",
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tst.path, nil)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != StatusOK {
+				t.Fatalf("wanted: %d. got: %d", StatusOK, rw.Code)
+			}
+			if o := strings.Join(rw.Header().Values("X-Order"), ","); o != tst.order {
+				t.Fatalf("wanted: %s. got: %s", tst.order, o)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Paths["/g/secret"].Get
+	if op.Security == nil || (*op.Security)[0]["key"] == nil || op.Responses["401"] == nil {
+		t.Fatalf("middleware didn't document the operation")
+	}
+	if op := doc.Paths["/skipped"].Get; op.Security != nil || op.Responses["401"] != nil {
+		t.Fatalf("excluded middleware documented the operation")
+	}
+}
+
+func TestEndpointMiddlewaresDuplicate(t *testing.T) {
+	type tt struct {
+		name string
+		ec   EndpointConfig
+	}
+	tsts := []tt{
+		{
+			name: "same as global",
+			ec:   NewEndpointConfig("/", testHandler).WithMiddlewares(testOrderMiddleware("global")),
+		}, {
+			name: "repeated",
+			ec: NewEndpointConfig("/", testHandler).
+				WithMiddlewares(testOrderMiddleware("a"), testOrderMiddleware("a")),
+		}, {
+			name: "no id",
+			ec:   NewEndpointConfig("/", testHandler).WithMiddlewares(testOrderMiddleware("")),
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			app := newTestApp(t)
+			if err := app.Apply(testOrderMiddleware("global")); err != nil {
+				t.Fatal(err)
+			}
+			app.Get(tst.ec)
+			if _, err := app.OpenAPI(); err == nil {
+				t.Fatalf("wanted an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package gate
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The catch-all segment mounted handlers are routed with
+const mountParam = "filepath"
+
+var mountMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+type mountedApp struct {
+	prefix string
+	app    *App
+}
+
+// Serves every request under prefix using h. The prefix is stripped
+// from the request's path before h is called. Requests still go
+// through the app's middlewares and panic handler. Mounted handlers
+// aren't documented.
+func (app *App) Mount(prefix string, h http.Handler) {
+	prefix = cleanPrefix(prefix)
+	var err error
+	switch {
+	case prefix == "":
+		err = fmt.Errorf("mount prefix can't be empty")
+	case h == nil:
+		err = fmt.Errorf("nil handler mounted at %q", prefix)
+	}
+	app.mount(prefix, mountMethods, func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		h.ServeHTTP(rc.ResponseWriter, stripPrefix(rc.Request, rc.Param(mountParam)))
+		return nil, nil
+	}, err)
+}
+
+// Registers h for methods on every path under prefix. The rest
+// of the path is the mountParam param. err fails the mount
+func (app *App) mount(prefix string, methods []string, h Handler, err error) {
+	if err == nil && strings.ContainsAny(prefix, ":*") {
+		err = fmt.Errorf("mount prefix %q can't have params", prefix)
+	}
+	for _, m := range methods {
+		m := m
+		ec := NewEndpointConfig(prefix+"/*"+mountParam, h)
+		ec.method = m
+		ec.err = err
+		ec.undocumented = true
+		app.registerEndpoint(ec, func(path string, h httprouter.Handle) {
+			app.router.Handle(m, path, h)
+		})
+	}
+}
+
+// Serves sub under prefix. The paths of sub's OpenAPI document are
+// added to the app's under prefix, along with its components.
+func (app *App) MountApp(prefix string, sub *App) {
+	if sub == nil {
+		app.Mount(prefix, nil)
+		return
+	}
+	app.Mount(prefix, sub)
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.mounts = append(app.mounts, mountedApp{
+		prefix: cleanPrefix(prefix),
+		app:    sub,
+	})
+}
+
+// A shallow copy of r whose path is path
+func stripPrefix(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// Adds the documents of the mounted apps to t
+func (app *App) mergeMounts(t *openapi3.T) error {
+	for _, m := range app.mounts {
+		doc, err := m.app.OpenAPI()
+		if err != nil {
+			return wrapErr(err, m.prefix)
+		}
+		for k, v := range doc.Paths {
+			p := m.prefix + k
+			if _, ok := t.Paths[p]; ok {
+				return wrapErr(fmt.Errorf("path %q documented twice", p), m.prefix)
+			}
+			t.Paths[p] = v
+		}
+		for k, v := range doc.Components.Schemas {
+			if err := mergeComponent(t.Components.Schemas, k, v); err != nil {
+				return wrapErr(err, m.prefix)
+			}
+		}
+		for k, v := range doc.Components.SecuritySchemes {
+			if err := mergeComponent(t.Components.SecuritySchemes, k, v); err != nil {
+				return wrapErr(err, m.prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// Adds v to cs under name. A component of the same name
+// must describe the same thing.
+func mergeComponent[T interface{ MarshalJSON() ([]byte, error) }](cs map[string]T, name string, v T) error {
+	if have, ok := cs[name]; ok {
+		a, err := have.MarshalJSON()
+		if err != nil {
+			return wrapErr(err)
+		}
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return wrapErr(err)
+		}
+		if !bytes.Equal(a, b) {
+			return fmt.Errorf("conflicting definitions of component %q", name)
+		}
+		return nil
+	}
+	cs[name] = v
+	return nil
+}
@@ -0,0 +1,163 @@
+package gate
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(testOrderMiddleware("global")); err != nil {
+		t.Fatal(err)
+	}
+	app.SetPanicHandler(func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		w.WriteHeader(StatusServiceUnavailable)
+	})
+	app.Get(NewEndpointConfig("/users/:id", testHandler))
+	app.Mount("/legacy/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("legacy")
+		}
+		w.WriteHeader(StatusAccepted)
+		io.WriteString(w, r.Method+" "+r.URL.Path)
+	}))
+
+	sub := newTestApp(t)
+	if err := sub.Apply(testOrderMiddleware("sub")); err != nil {
+		t.Fatal(err)
+	}
+	sub.Get(NewEndpointConfig("/users/:id", testHandler).WithOperationID("subUser"))
+	sub.Post(EndpointConfig{
+		Path:    "/items",
+		Handler: testHandler,
+		Payload: EndpointPayload{RequestPayload: &testPld{}, ResponsePayload: &testPld{}},
+	})
+	app.MountApp("/v2", sub)
+
+	type tt struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		out    string
+		order  string
+	}
+	tsts := []tt{
+		{
+			name:   "handler",
+			method: http.MethodPut,
+			path:   "/legacy/a/b?x=1",
+			status: StatusAccepted,
+			out:    "PUT /a/b",
+			order:  "global",
+		}, {
+			name:   "handler root",
+			method: http.MethodGet,
+			path:   "/legacy/",
+			status: StatusAccepted,
+			out:    "GET /",
+			order:  "global",
+		}, {
+			name:   "panic",
+			method: http.MethodGet,
+			path:   "/legacy/panic",
+			status: StatusServiceUnavailable,
+			order:  "global",
+		}, {
+			name:   "app",
+			method: http.MethodGet,
+			path:   "/v2/users/1",
+			status: StatusOK,
+			out:    `{"key":"a","value":"b"}`,
+			order:  "global,sub",
+		}, {
+			name:   "app payload",
+			method: http.MethodPost,
+			path:   "/v2/items",
+			body:   `{"key":"k"}`,
+			status: StatusOK,
+			out:    `{"key":"a","value":"b"}`,
+			order:  "global,sub",
+		}, {
+			name:   "app not found",
+			method: http.MethodGet,
+			path:   "/v2/nope",
+			status: StatusNotFound,
+			out:    "404 page not found\n",
+			order:  "global",
+		}, {
+			name:   "own endpoint",
+			method: http.MethodGet,
+			path:   "/users/1",
+			status: StatusOK,
+			out:    `{"key":"a","value":"b"}`,
+			order:  "global",
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(tst.method, tst.path, strings.NewReader(tst.body))
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+			if o := strings.Join(rw.Header().Values("X-Order"), ","); o != tst.order {
+				t.Fatalf("wanted: %s. got: %s", tst.order, o)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/users/{id}", "/v2/users/{id}", "/v2/items"} {
+		if doc.Paths[p] == nil {
+			t.Fatalf("%s not documented", p)
+		}
+	}
+	if doc.Paths["/v2/users/{id}"].Get.OperationID != "subUser" {
+		t.Fatalf("sub app operation not merged")
+	}
+	for p := range doc.Paths {
+		if strings.HasPrefix(p, "/legacy") {
+			t.Fatalf("mounted handler documented: %s", p)
+		}
+	}
+}
+
+func TestMountErrors(t *testing.T) {
+	type tt struct {
+		name  string
+		mount func(app *App)
+	}
+	tsts := []tt{
+		{
+			name:  "empty prefix",
+			mount: func(app *App) { app.Mount("/", http.NotFoundHandler()) },
+		}, {
+			name:  "param in prefix",
+			mount: func(app *App) { app.Mount("/:id", http.NotFoundHandler()) },
+		}, {
+			name:  "nil app",
+			mount: func(app *App) { app.MountApp("/v2", nil) },
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			app := newTestApp(t)
+			tst.mount(app)
+			if _, err := app.OpenAPI(); err == nil {
+				t.Fatalf("wanted an error")
+			}
+		})
+	}
+}
@@ -1,45 +1,280 @@
 package gate
 
 import (
-	"log"
 	"reflect"
 	"regexp"
-
-	json "github.com/goccy/go-json"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
 )
 
 // type Info openapi3.Info
 
+var (
+	pathParamRegexp = regexp.MustCompile(`/:[\p{L}_][\p{L}_\p{Nd}]*`)
+	catchAllRegexp  = regexp.MustCompile(`/\*[\p{L}_][\p{L}_\p{Nd}]*$`)
+)
+
 func pathParams(r string) []string {
-	reg := regexp.MustCompile(`/:[\p{L}_][\p{L}_\p{Nd}]*`)
-	return reg.FindAllString(r, -1)
+	return pathParamRegexp.FindAllString(r, -1)
+}
+
+// The catch-all segment of r, like "/*filepath". Empty if none
+func catchAllParam(r string) string {
+	return catchAllRegexp.FindString(r)
+}
+
+const openapiVersion = "3.0.3"
+
+var noPayloadType = reflect.TypeOf(NoPayload{})
+
+// A nil Payload or a NoPayload means there's no body to speak of
+func hasBody(p Payload) bool {
+	return p != nil && payloadType(p) != noPayloadType
+}
+
+// Declared path params are documented with their constraints.
+// The rest are plain strings.
+func (ep *endpoint) pathParameters() openapi3.Parameters {
+	declared := map[string]Param{}
+	for _, p := range ep.params {
+		if p.in() == PARAM_IN_PATH {
+			declared[p.name()] = p
+		}
+	}
+
+	_, names := ep.pathDetails()
+	var ps openapi3.Parameters
+	for _, n := range names {
+		if p, ok := declared[n]; ok {
+			ps = append(ps, &openapi3.ParameterRef{Value: openapiParameter(p)})
+			continue
+		}
+		ps = append(ps, &openapi3.ParameterRef{
+			Value: openapi3.NewPathParameter(n).
+				WithSchema(openapi3.NewStringSchema()),
+		})
+	}
+	return ps
 }
 
-func queryParams(p Payload) []string {
-	if p == nil {
-		return nil
+// Struct shaped query payloads get a parameter per property.
+// Anything map shaped is documented as a single free form object.
+func (ep *endpoint) queryParameters(sg *schemaGen) (openapi3.Parameters, error) {
+	if !hasBody(ep.queryPayload) {
+		return nil, nil
 	}
-	bs, err := p.Marshal()
+	typ := payloadType(ep.queryPayload)
+	if _, ok := ep.queryPayload.(QueryDeserializable); !ok && typ.Kind() == reflect.Struct {
+		return structQueryParameters(sg, typ)
+	}
+
+	sr, err := sg.schemaFromType(typ)
 	if err != nil {
-		log.Println(wrapErr(err, "Marshal failed"))
-		return nil
+		return nil, wrapErr(err)
+	}
+
+	s := sr.Value
+	if len(s.Properties) == 0 {
+		p := openapi3.NewQueryParameter("params").WithSchema(s)
+		p.Style = openapi3.SerializationForm
+		explode := true
+		p.Explode = &explode
+		return openapi3.Parameters{{Value: p}}, nil
+	}
+
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for n := range s.Properties {
+		names = append(names, n)
 	}
+	sort.Strings(names)
 
-	v := map[string]interface{}{}
-	if err := json.Unmarshal(bs, &v); err != nil {
-		log.Println(wrapErr(err, "json unmarshal to map failed"))
-		return nil
+	var ps openapi3.Parameters
+	for _, n := range names {
+		p := openapi3.NewQueryParameter(n).WithRequired(required[n])
+		p.Schema = s.Properties[n]
+		ps = append(ps, &openapi3.ParameterRef{Value: p})
+	}
+	return ps, nil
+}
+
+// Parameters for a struct bound using decodeQuery
+func structQueryParameters(sg *schemaGen, typ reflect.Type) (openapi3.Parameters, error) {
+	var ps openapi3.Parameters
+	for _, f := range queryFields(typ) {
+		ft := f.typ
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		sr, err := sg.schemaFromType(ft)
+		if err != nil {
+			return nil, wrapErr(err, f.name)
+		}
+		if sr.Ref == "" {
+			if ft == timeType && f.format != "" {
+				sr.Value.Format = ""
+				if f.format == "2006-01-02" {
+					sr.Value.Format = "date"
+				}
+			}
+			if f.hasDef {
+				sr.Value.Default = queryDefault(ft, f)
+			}
+			if len(f.enum) > 0 {
+				es, et := sr.Value, ft
+				if es.Type == openapi3.TypeArray && es.Items.Ref == "" {
+					es, et = es.Items.Value, ft.Elem()
+				}
+				for _, e := range f.enum {
+					es.Enum = append(es.Enum, queryDefault(et, tagField{def: []string{e}}))
+				}
+			}
+		}
+		p := openapi3.NewQueryParameter(f.name).WithRequired(f.required)
+		p.Schema = sr
+		ps = append(ps, &openapi3.ParameterRef{Value: p})
+	}
+	return ps, nil
+}
+
+// The default of f as a value that marshals the way the schema expects
+func queryDefault(typ reflect.Type, f tagField) interface{} {
+	raw := strings.Join(f.def, ",")
+	switch typ {
+	case timeType, durationType:
+		return raw
+	}
+	v := reflect.New(typ).Elem()
+	if err := setQueryValue(v, f.def, f.format); err != nil {
+		return raw
 	}
-	var keys []string
-	for key := range v {
-		keys = append(keys, key)
+	if v.Kind() == reflect.Struct || v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return raw
 	}
-	return keys
+	return v.Interface()
 }
 
-func schemaFromType(typ reflect.Type) (openapi3.Schema, error) {
-	// TODO
-	return *openapi3.NewSchema(), nil
+// Whether the operation documents a 200 response
+func (ep *endpoint) documentsOK() bool {
+	return ep.stream != nil || ep.responsePayload != nil || len(ep.responses) == 0
+}
+
+// Whether code is one of the responses the operation documents
+func (ep *endpoint) declaresStatus(code int) bool {
+	if _, ok := ep.responses[code]; ok {
+		return true
+	}
+	return code == StatusOK && ep.documentsOK()
+}
+
+// Builds the openapi3.Operation describing ep
+func (ep *endpoint) operation(sg *schemaGen) (*openapi3.Operation, error) {
+	op := openapi3.NewOperation()
+	op.Summary = ep.summary
+	op.Description = ep.description
+	op.Tags = ep.tags
+	op.OperationID = ep.operationID
+	op.Deprecated = ep.deprecated
+	if ep.security != nil {
+		sec := ep.security
+		op.Security = &sec
+	}
+	op.Parameters = ep.pathParameters()
+	qps, err := ep.queryParameters(sg)
+	if err != nil {
+		return nil, wrapErr(err, "query")
+	}
+	op.Parameters = append(op.Parameters, qps...)
+	for _, p := range ep.params {
+		if p.in() == PARAM_IN_HEADER || p.in() == PARAM_IN_COOKIE {
+			op.Parameters = append(op.Parameters, &openapi3.ParameterRef{Value: openapiParameter(p)})
+		}
+	}
+
+	if hasBody(ep.requestPayload) {
+		sr, err := ep.requestSchema(sg)
+		if err != nil {
+			return nil, wrapErr(err, "request")
+		}
+		op.RequestBody = &openapi3.RequestBodyRef{
+			Value: openapi3.NewRequestBody().
+				WithRequired(true).
+				WithSchemaRef(sr, codecTypes(payloadCodecs(ep.requestPayload, ep.codecs, nil))),
+		}
+	}
+
+	responses := map[int]Payload{}
+	if ep.stream == nil && ep.documentsOK() {
+		responses[StatusOK] = ep.responsePayload
+	}
+	for code, p := range ep.responses {
+		responses[code] = p
+	}
+	problems := ep.errs != nil && ep.errs.problems
+	if _, ok := responses[StatusUnprocessableEntity]; ep.validate && !ok {
+		responses[StatusUnprocessableEntity] = &ValidationError{}
+		if problems {
+			responses[StatusUnprocessableEntity] = &Problem{}
+		}
+	}
+
+	op.Responses = openapi3.Responses{}
+	if ep.stream != nil {
+		res, err := streamResponse(sg, ep.stream)
+		if err != nil {
+			return nil, wrapErr(err, "stream")
+		}
+		op.Responses[strconv.Itoa(StatusOK)] = &openapi3.ResponseRef{Value: res}
+	}
+	for code, p := range responses {
+		cs := ep.codecs
+		switch p.(type) {
+		case *ValidationError, *Problem:
+			// Always answered as JSON
+			cs = nil
+		}
+		res, err := response(sg, code, p, cs)
+		if err != nil {
+			return nil, wrapErr(err, strconv.Itoa(code))
+		}
+		op.Responses[strconv.Itoa(code)] = &openapi3.ResponseRef{Value: res}
+	}
+	if problems {
+		res := openapi3.NewResponse().
+			WithDescription("Problem details").
+			WithContent(openapi3.NewContentWithSchemaRef(sg.problemSchema(), []string{ContentTypePROBLEM.String()}))
+		op.Responses["default"] = &openapi3.ResponseRef{Value: res}
+	}
+	for _, m := range ep.middlewares {
+		if m.Document != nil {
+			m.Document(op)
+		}
+	}
+	return op, nil
+}
+
+func response(sg *schemaGen, code int, p Payload, codecs []Codec) (*openapi3.Response, error) {
+	desc, ok := httpStatusMessage[code]
+	if !ok {
+		desc = strconv.Itoa(code)
+	}
+	res := openapi3.NewResponse().WithDescription(desc)
+	if !hasBody(p) || !bodyAllowed(code) {
+		return res, nil
+	}
+
+	sr, err := sg.payloadSchema(p)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	res.WithContent(openapi3.NewContentWithSchemaRef(
+		sr, codecTypes(payloadCodecs(p, codecs, nil)),
+	))
+	return res, nil
 }
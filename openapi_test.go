@@ -0,0 +1,335 @@
+package gate
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+type testQueryPld struct {
+	Page  int    `query:"page,required"`
+	Query string `json:"q,omitempty"`
+}
+
+func (p testQueryPld) Marshal() ([]byte, error) {
+	return nil, nil
+}
+
+func (p *testQueryPld) Unmarshal(src []byte) error {
+	return nil
+}
+
+func (testQueryPld) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	app, err := New(AppOptions{
+		Info: openapi3.Info{
+			Title:   "test api",
+			Version: "0.0.0",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func testHandler(rc *RequestCtx, rd *RequestData) (Payload, error) {
+	return &testPld{Key: "a", Value: "b"}, nil
+}
+
+func TestOpenAPI(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(EndpointConfig{
+		Path:    "/users/:id",
+		Handler: testHandler,
+		Payload: EndpointPayload{
+			ResponsePayload: &testPld{},
+		},
+	})
+	app.Post(EndpointConfig{
+		Path:    "/users",
+		Handler: testHandler,
+		Payload: NewEndpointPayload(&testPld{}, &testQueryPld{}, &testPld{}),
+	})
+	app.Delete(EndpointConfig{
+		Path:    "/users/:id",
+		Handler: testHandler,
+		Payload: NewEndpointPayload(NOPE(), &QueryPayload{}),
+	})
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Info.Title != "test api" {
+		t.Fatalf("wanted title: test api. got: %s", doc.Info.Title)
+	}
+
+	pi := doc.Paths.Find("/users/{id}")
+	if pi == nil {
+		t.Fatalf("path /users/{id} missing")
+	}
+	if pi.Get == nil || pi.Delete == nil {
+		t.Fatalf("get and delete operations must be present")
+	}
+	if p := pi.Get.Parameters.GetByInAndName(openapi3.ParameterInPath, "id"); p == nil || !p.Required {
+		t.Fatalf("required path param id missing")
+	}
+	mt := pi.Get.Responses.Get(StatusOK).Value.Content.Get(ContentTypeJSON.String())
+	if mt == nil || len(mt.Schema.Value.Properties) != 2 {
+		t.Fatalf("response schema missing")
+	}
+	if pi.Delete.RequestBody != nil {
+		t.Fatalf("NoPayload must not produce a request body")
+	}
+	if p := pi.Delete.Parameters.GetByInAndName(openapi3.ParameterInQuery, "params"); p == nil {
+		t.Fatalf("free form query param missing")
+	}
+
+	post := doc.Paths.Find("/users").Post
+	if post == nil || post.RequestBody == nil {
+		t.Fatalf("post request body missing")
+	}
+	if post.RequestBody.Value.Content.Get(ContentTypeJSON.String()) == nil {
+		t.Fatalf("post request body must be %s", ContentTypeJSON)
+	}
+	page := post.Parameters.GetByInAndName(openapi3.ParameterInQuery, "page")
+	q := post.Parameters.GetByInAndName(openapi3.ParameterInQuery, "q")
+	if page == nil || !page.Required || q == nil || q.Required {
+		t.Fatalf("query params not derived from struct")
+	}
+}
+
+func TestOpenAPILateRegistration(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/a", testHandler))
+	if _, err := app.OpenAPI(); err != nil {
+		t.Fatal(err)
+	}
+
+	app.Get(NewEndpointConfig("/b", testHandler))
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 2 {
+		t.Fatalf("wanted 2 paths. got: %d", len(doc.Paths))
+	}
+
+	rw := httptest.NewRecorder()
+	app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/b", nil))
+	if rw.Code != StatusOK {
+		t.Fatalf("wanted: %d. got: %d", StatusOK, rw.Code)
+	}
+}
+
+func TestServeDocs(t *testing.T) {
+	app, err := New(AppOptions{
+		Info: openapi3.Info{
+			Title:   "docs api",
+			Version: "0.0.0",
+		},
+		DocsPrefix: "/docs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Get(NewEndpointConfig("/a", testHandler))
+
+	type tt struct {
+		url         string
+		contentType string
+		contains    string
+	}
+	tsts := []tt{
+		{
+			url:         "/docs",
+			contentType: ContentTypeHTML.String(),
+			contains:    "<title>docs api</title>",
+		}, {
+			url:         "/docs/openapi.json",
+			contentType: ContentTypeJSON.String(),
+			contains:    `"openapi":"3.0.3"`,
+		}, {
+			url:         "/docs/openapi.yaml",
+			contentType: ContentTypeYAML.String(),
+			contains:    "openapi: 3.0.3",
+		}, {
+			url:         "/docs/assets/docs.js",
+			contentType: "application/javascript",
+			contains:    "data-spec",
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.url, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tst.url, nil))
+			if rw.Code != StatusOK {
+				t.Fatalf("wanted: %d. got: %d", StatusOK, rw.Code)
+			}
+			if ct := rw.Header().Get("Content-Type"); ct != tst.contentType {
+				t.Fatalf("wanted content type: %s. got: %s", tst.contentType, ct)
+			}
+			if !strings.Contains(rw.Body.String(), tst.contains) {
+				t.Fatalf("body does not contain %q", tst.contains)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 1 {
+		t.Fatalf("docs routes must not be documented. got paths: %v", doc.Paths)
+	}
+
+	if err := app.ServeDocs("/docs"); err == nil {
+		t.Fatalf("registering docs twice must fail")
+	}
+}
+
+func TestServeDocsConflict(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.ServeDocs("/"); err != nil {
+		t.Fatal(err)
+	}
+	// Only the page route conflicts, with the spec served above
+	if err := app.ServeDocs("/openapi.json"); err == nil {
+		t.Fatalf("conflicting docs routes registered")
+	}
+	rw := httptest.NewRecorder()
+	app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/openapi.json/openapi.yaml", nil))
+	if rw.Code != StatusNotFound {
+		t.Fatalf("routes of the failed call kept. got: %d", rw.Code)
+	}
+}
+
+func TestOperationMetadata(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/users/:id", testHandler).
+		WithSummary("Get a user").
+		WithDescription("Fetches a user by id").
+		WithTags("users", "public").
+		WithOperationID("getUser").
+		WithDeprecated(true))
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Paths["/users/{id}"].Get
+	if op.Summary != "Get a user" || op.Description != "Fetches a user by id" {
+		t.Fatalf("summary or description not copied")
+	}
+	if op.OperationID != "getUser" || !op.Deprecated {
+		t.Fatalf("operationId or deprecated not copied")
+	}
+	if len(op.Tags) != 2 || op.Tags[0] != "users" || op.Tags[1] != "public" {
+		t.Fatalf("wanted tags [users public]. got: %v", op.Tags)
+	}
+
+	app.Delete(NewEndpointConfig("/users/:id", testHandler).WithOperationID("getUser"))
+	if _, err := app.OpenAPI(); err == nil {
+		t.Fatalf("duplicate operationId must be rejected")
+	}
+}
+
+func TestWithTagsCopies(t *testing.T) {
+	base := NewEndpointConfig("/", testHandler).WithTags("a", "b", "c")
+	base.Tags = base.Tags[:2]
+	x := base.WithTags("x")
+	y := base.WithTags("y")
+	if strings.Join(x.Tags, ",") != "a,b,x" || strings.Join(y.Tags, ",") != "a,b,y" {
+		t.Fatalf("derived configs share tags: %v %v", x.Tags, y.Tags)
+	}
+	if len(base.Tags) != 2 {
+		t.Fatalf("base modified: %v", base.Tags)
+	}
+}
+
+func TestResponses(t *testing.T) {
+	app := newTestApp(t)
+	app.Post(NewEndpointConfig("/users/:id", func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		switch rd.Params.ByName("id") {
+		case "new":
+			rc.SetStatus(StatusCreated)
+			return &testPld{Key: "id", Value: "1"}, nil
+		case "empty":
+			rc.SetStatus(StatusNoContent)
+			return &testPld{Key: "id", Value: "1"}, nil
+		case "teapot":
+			rc.SetStatus(StatusTeapot)
+			return nil, nil
+		}
+		rc.SetStatus(StatusNotFound)
+		return NewString("no such user"), nil
+	}).
+		WithResponse(StatusCreated, &testPld{}).
+		WithResponse(StatusNoContent, NOPE()).
+		WithResponse(StatusNotFound, NewString("")))
+
+	type tt struct {
+		url    string
+		status int
+		body   string
+	}
+	tsts := []tt{
+		{url: "/users/new", status: StatusCreated, body: `{"key":"id","value":"1"}`},
+		{url: "/users/empty", status: StatusNoContent, body: ""},
+		{url: "/users/nope", status: StatusNotFound, body: `"no such user"`},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.url, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, tst.url, nil))
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if rw.Body.String() != tst.body {
+				t.Fatalf("wanted body: %s. got: %s", tst.body, rw.Body.String())
+			}
+		})
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	rw := httptest.NewRecorder()
+	app.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/users/teapot", nil))
+	if rw.Code != StatusTeapot {
+		t.Fatalf("wanted: %d. got: %d", StatusTeapot, rw.Code)
+	}
+	if !strings.Contains(logs.String(), "status 418 not declared") {
+		t.Fatalf("undeclared status not logged. got: %q", logs.String())
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := doc.Paths["/users/{id}"].Post.Responses
+	if len(rs) != 3 || rs.Get(StatusOK) != nil {
+		t.Fatalf("wanted exactly the declared responses. got: %d", len(rs))
+	}
+	if rs.Get(StatusCreated).Value.Content.Get(ContentTypeJSON.String()) == nil {
+		t.Fatalf("201 content missing")
+	}
+	if rs.Get(StatusNoContent).Value.Content != nil {
+		t.Fatalf("204 must not have content")
+	}
+	if rs.Get(StatusNotFound).Value.Content.Get(ContentTypeJSON.String()) == nil {
+		t.Fatalf("404 content missing")
+	}
+}
@@ -1,19 +1,39 @@
 package gate
 
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/julienschmidt/httprouter"
+)
+
 type ParamIn int
 
 const (
 	PARAM_IN_INVALID ParamIn = iota
 	PARAM_IN_PATH
 	PARAM_IN_QUERY
+	PARAM_IN_HEADER
+	PARAM_IN_COOKIE
 )
 
 func (pi ParamIn) String() string {
 	switch pi {
 	case PARAM_IN_PATH:
-		return "in"
+		return "path"
 	case PARAM_IN_QUERY:
 		return "query"
+	case PARAM_IN_HEADER:
+		return "header"
+	case PARAM_IN_COOKIE:
+		return "cookie"
 	}
 	return ""
 }
@@ -22,8 +42,501 @@ type Param interface {
 	name() string
 	in() ParamIn
 	required() bool
+	description() string
+	schema() *openapi3.Schema
+	// Returns an error describing why v is unacceptable
+	check(v string) error
+	// Whether a failed check answers with StatusNotFound
+	// instead of StatusBadRequest
+	notFound() bool
 }
 
 type Params interface {
 	Params() []Param
 }
+
+// Converts p to its openapi3 representation
+func openapiParameter(p Param) *openapi3.Parameter {
+	return &openapi3.Parameter{
+		Name:        p.name(),
+		In:          p.in().String(),
+		Required:    p.required(),
+		Description: p.description(),
+		Schema:      openapi3.NewSchemaRef("", p.schema()),
+	}
+}
+
+// The type a parameter's value must parse as
+type ParamType int
+
+const (
+	ParamTypeString ParamType = iota
+	ParamTypeInt
+	ParamTypeNumber
+	ParamTypeBool
+	ParamTypeUUID
+)
+
+func (pt ParamType) schema() *openapi3.Schema {
+	switch pt {
+	case ParamTypeInt:
+		return openapi3.NewInt64Schema()
+	case ParamTypeNumber:
+		return openapi3.NewFloat64Schema()
+	case ParamTypeBool:
+		return openapi3.NewBoolSchema()
+	case ParamTypeUUID:
+		return openapi3.NewStringSchema().WithPattern(uuidPattern)
+	}
+	return openapi3.NewStringSchema()
+}
+
+// Returns v as a float64 for range checks. Strings are
+// measured by their length.
+func (pt ParamType) parse(v string) (float64, error) {
+	switch pt {
+	case ParamTypeInt:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", v)
+		}
+		return float64(i), nil
+	case ParamTypeNumber:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	case ParamTypeBool:
+		if _, err := strconv.ParseBool(v); err != nil {
+			return 0, fmt.Errorf("%q is not a boolean", v)
+		}
+		return 0, nil
+	case ParamTypeUUID:
+		if _, err := ParseUUID(v); err != nil {
+			return 0, fmt.Errorf("%q is not a UUID", v)
+		}
+		return 0, nil
+	}
+	return float64(utf8.RuneCountInString(v)), nil
+}
+
+var patternCache sync.Map
+
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(p); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	patternCache.Store(p, re)
+	return re, nil
+}
+
+// Declares the type of a path parameter and the constraints its
+// value must satisfy. Requests that don't satisfy them are rejected
+// before the handler runs: with StatusNotFound when NotFound is set
+// and with StatusBadRequest otherwise.
+// Min and Max bound the value of ParamTypeInt and ParamTypeNumber
+// parameters and the length of ParamTypeString ones.
+type PathParam struct {
+	Name        string
+	Description string
+	Type        ParamType
+	Pattern     string
+	Min         *float64
+	Max         *float64
+	OneOf       []string
+	NotFound    bool
+}
+
+func NewPathParam(name string, typ ParamType) PathParam {
+	return PathParam{
+		Name: name,
+		Type: typ,
+	}
+}
+
+func (pp PathParam) WithDescription(d string) PathParam {
+	pp.Description = d
+	return pp
+}
+
+func (pp PathParam) WithPattern(p string) PathParam {
+	pp.Pattern = p
+	return pp
+}
+
+func (pp PathParam) WithMin(min float64) PathParam {
+	pp.Min = &min
+	return pp
+}
+
+func (pp PathParam) WithMax(max float64) PathParam {
+	pp.Max = &max
+	return pp
+}
+
+func (pp PathParam) WithOneOf(vs ...string) PathParam {
+	pp.OneOf = append(pp.OneOf, vs...)
+	return pp
+}
+
+func (pp PathParam) WithNotFound(b bool) PathParam {
+	pp.NotFound = b
+	return pp
+}
+
+func (pp PathParam) name() string {
+	return pp.Name
+}
+
+func (PathParam) in() ParamIn {
+	return PARAM_IN_PATH
+}
+
+func (PathParam) required() bool {
+	return true
+}
+
+func (pp PathParam) description() string {
+	return pp.Description
+}
+
+func (pp PathParam) notFound() bool {
+	return pp.NotFound
+}
+
+func (pp PathParam) schema() *openapi3.Schema {
+	return pp.rules().schema()
+}
+
+func (pp PathParam) check(v string) error {
+	return pp.rules().check(v)
+}
+
+func (pp PathParam) rules() paramRules {
+	return paramRules{
+		typ:     pp.Type,
+		pattern: pp.Pattern,
+		min:     pp.Min,
+		max:     pp.Max,
+		oneOf:   pp.OneOf,
+	}
+}
+
+// Declares a request header. Missing required headers and values
+// that don't satisfy the constraints are rejected with
+// StatusBadRequest before the handler runs.
+type HeaderParam struct {
+	Name        string
+	Description string
+	Type        ParamType
+	Required    bool
+	Pattern     string
+	OneOf       []string
+}
+
+func NewHeaderParam(name string, typ ParamType) HeaderParam {
+	return HeaderParam{
+		Name: name,
+		Type: typ,
+	}
+}
+
+func (hp HeaderParam) WithDescription(d string) HeaderParam {
+	hp.Description = d
+	return hp
+}
+
+func (hp HeaderParam) WithRequired(b bool) HeaderParam {
+	hp.Required = b
+	return hp
+}
+
+func (hp HeaderParam) WithPattern(p string) HeaderParam {
+	hp.Pattern = p
+	return hp
+}
+
+func (hp HeaderParam) WithOneOf(vs ...string) HeaderParam {
+	hp.OneOf = append(hp.OneOf, vs...)
+	return hp
+}
+
+func (hp HeaderParam) name() string {
+	return hp.Name
+}
+
+func (HeaderParam) in() ParamIn {
+	return PARAM_IN_HEADER
+}
+
+func (hp HeaderParam) required() bool {
+	return hp.Required
+}
+
+func (hp HeaderParam) description() string {
+	return hp.Description
+}
+
+func (HeaderParam) notFound() bool {
+	return false
+}
+
+func (hp HeaderParam) schema() *openapi3.Schema {
+	return hp.rules().schema()
+}
+
+func (hp HeaderParam) check(v string) error {
+	return hp.rules().check(v)
+}
+
+func (hp HeaderParam) rules() paramRules {
+	return paramRules{
+		typ:     hp.Type,
+		pattern: hp.Pattern,
+		oneOf:   hp.OneOf,
+	}
+}
+
+// Same as HeaderParam for cookies
+type CookieParam struct {
+	Name        string
+	Description string
+	Type        ParamType
+	Required    bool
+	Pattern     string
+	OneOf       []string
+}
+
+func NewCookieParam(name string, typ ParamType) CookieParam {
+	return CookieParam{
+		Name: name,
+		Type: typ,
+	}
+}
+
+func (cp CookieParam) WithDescription(d string) CookieParam {
+	cp.Description = d
+	return cp
+}
+
+func (cp CookieParam) WithRequired(b bool) CookieParam {
+	cp.Required = b
+	return cp
+}
+
+func (cp CookieParam) WithPattern(p string) CookieParam {
+	cp.Pattern = p
+	return cp
+}
+
+func (cp CookieParam) WithOneOf(vs ...string) CookieParam {
+	cp.OneOf = append(cp.OneOf, vs...)
+	return cp
+}
+
+func (cp CookieParam) name() string {
+	return cp.Name
+}
+
+func (CookieParam) in() ParamIn {
+	return PARAM_IN_COOKIE
+}
+
+func (cp CookieParam) required() bool {
+	return cp.Required
+}
+
+func (cp CookieParam) description() string {
+	return cp.Description
+}
+
+func (CookieParam) notFound() bool {
+	return false
+}
+
+func (cp CookieParam) schema() *openapi3.Schema {
+	return cp.rules().schema()
+}
+
+func (cp CookieParam) check(v string) error {
+	return cp.rules().check(v)
+}
+
+func (cp CookieParam) rules() paramRules {
+	return paramRules{
+		typ:     cp.Type,
+		pattern: cp.Pattern,
+		oneOf:   cp.OneOf,
+	}
+}
+
+// Constraints shared by every kind of Param
+type paramRules struct {
+	typ     ParamType
+	pattern string
+	min     *float64
+	max     *float64
+	oneOf   []string
+}
+
+func (pr paramRules) schema() *openapi3.Schema {
+	s := pr.typ.schema()
+	if pr.pattern != "" {
+		s.Pattern = pr.pattern
+	}
+	for _, v := range pr.oneOf {
+		s.Enum = append(s.Enum, v)
+	}
+
+	switch pr.typ {
+	case ParamTypeInt, ParamTypeNumber:
+		s.Min = pr.min
+		s.Max = pr.max
+	case ParamTypeString:
+		if pr.min != nil {
+			s.MinLength = uint64(*pr.min)
+		}
+		if pr.max != nil {
+			max := uint64(*pr.max)
+			s.MaxLength = &max
+		}
+	}
+	return s
+}
+
+func (pr paramRules) check(v string) error {
+	n, err := pr.typ.parse(v)
+	if err != nil {
+		return err
+	}
+
+	if pr.pattern != "" {
+		re, err := compilePattern(pr.pattern)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(v) {
+			return fmt.Errorf("%q does not match %s", v, pr.pattern)
+		}
+	}
+
+	if len(pr.oneOf) > 0 {
+		if err := checkEnum([]string{v}, pr.oneOf); err != nil {
+			return err
+		}
+	}
+
+	switch pr.typ {
+	case ParamTypeInt, ParamTypeNumber, ParamTypeString:
+		what := "value"
+		if pr.typ == ParamTypeString {
+			what = "length"
+		}
+		if pr.min != nil && n < *pr.min {
+			return fmt.Errorf("%s must be at least %v", what, *pr.min)
+		}
+		if pr.max != nil && n > *pr.max {
+			return fmt.Errorf("%s must be at most %v", what, *pr.max)
+		}
+	}
+	return nil
+}
+
+// Returns the pattern of p if it has one
+func paramPattern(p Param) string {
+	switch v := p.(type) {
+	case PathParam:
+		return v.Pattern
+	case HeaderParam:
+		return v.Pattern
+	case CookieParam:
+		return v.Pattern
+	}
+	return ""
+}
+
+// Derives the params of the struct sample points to from its `header`
+// or `cookie` tags. See tagField for the supported tags.
+func structParams(in ParamIn, sample interface{}) ([]Param, error) {
+	typ := reflect.TypeOf(sample)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, wrapErr(fmt.Errorf("%s params must be a struct. got %T", in, sample))
+	}
+
+	var ps []Param
+	for _, f := range tagFields(typ, in.String()) {
+		pt := fieldParamType(f.typ)
+		switch in {
+		case PARAM_IN_HEADER:
+			ps = append(ps, HeaderParam{Name: f.name, Type: pt, Required: f.required, OneOf: f.enum})
+		case PARAM_IN_COOKIE:
+			ps = append(ps, CookieParam{Name: f.name, Type: pt, Required: f.required, OneOf: f.enum})
+		default:
+			return nil, wrapErr(fmt.Errorf("%s params can't be declared using a struct", in))
+		}
+	}
+	return ps, nil
+}
+
+func fieldParamType(typ reflect.Type) ParamType {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == uuidType {
+		return ParamTypeUUID
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if typ != durationType {
+			return ParamTypeInt
+		}
+	case reflect.Float32, reflect.Float64:
+		return ParamTypeNumber
+	case reflect.Bool:
+		return ParamTypeBool
+	}
+	return ParamTypeString
+}
+
+// Binds path params into the struct dst points to using the
+// `path` tag. See tagField for the supported tags.
+func bindPath(params httprouter.Params, dst interface{}) error {
+	values := url.Values{}
+	for _, p := range params {
+		values[p.Key] = []string{p.Value}
+	}
+	return decodeValues(values, dst, "path")
+}
+
+// Binds request headers into the struct dst points to using the
+// `header` tag. Names are matched case insensitively.
+func bindHeader(h http.Header, dst interface{}) error {
+	values := url.Values{}
+	if typ := reflect.TypeOf(dst); typ != nil && typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+		for _, f := range tagFields(typ.Elem(), "header") {
+			if vs := h.Values(f.name); len(vs) > 0 {
+				values[f.name] = vs
+			}
+		}
+	}
+	return decodeValues(values, dst, "header")
+}
+
+// Binds request cookies into the struct dst points to using the
+// `cookie` tag.
+func bindCookie(cs []*http.Cookie, dst interface{}) error {
+	values := url.Values{}
+	for _, c := range cs {
+		values.Add(c.Name, c.Value)
+	}
+	return decodeValues(values, dst, "cookie")
+}
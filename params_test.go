@@ -0,0 +1,253 @@
+package gate
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testPathParams struct {
+	ID    int64  `path:"id"`
+	Org   UUID   `path:"org"`
+	Order string `path:"order" enum:"asc,desc"`
+}
+
+func TestPathParams(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(EndpointConfig{
+		Path: "/orgs/:org/users/:id/:order",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			var pp testPathParams
+			if err := rd.BindPath(&pp); err != nil {
+				return nil, err
+			}
+			return NewString(pp.Org.String() + " " + rc.Param("id") + " " + pp.Order), nil
+		},
+		Payload: EndpointPayload{
+			ResponsePayload: NewString(""),
+		},
+	}.WithParams(
+		NewPathParam("id", ParamTypeInt).WithMin(1).WithNotFound(true),
+		NewPathParam("org", ParamTypeUUID).WithDescription("org id"),
+		NewPathParam("order", ParamTypeString).WithOneOf("asc", "desc"),
+	))
+
+	org := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	type tt struct {
+		name   string
+		url    string
+		status int
+		out    string
+	}
+	tsts := []tt{
+		{
+			name:   "valid",
+			url:    "/orgs/" + org + "/users/12/asc",
+			status: StatusOK,
+			out:    `"` + org + ` 12 asc"`,
+		}, {
+			name:   "not an int",
+			url:    "/orgs/" + org + "/users/abc/asc",
+			status: StatusNotFound,
+		}, {
+			name:   "below min",
+			url:    "/orgs/" + org + "/users/0/asc",
+			status: StatusNotFound,
+		}, {
+			name:   "bad uuid",
+			url:    "/orgs/nope/users/1/asc",
+			status: StatusBadRequest,
+		}, {
+			name:   "bad enum",
+			url:    "/orgs/" + org + "/users/1/up",
+			status: StatusBadRequest,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tst.url, nil))
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := doc.Paths["/orgs/{org}/users/{id}/{order}"].Get.Parameters
+	id := ps.GetByInAndName("path", "id")
+	if id == nil || id.Schema.Value.Type != "integer" || id.Schema.Value.Min == nil {
+		t.Fatalf("id param not documented: %+v", id)
+	}
+	if o := ps.GetByInAndName("path", "order"); o == nil || len(o.Schema.Value.Enum) != 2 {
+		t.Fatalf("order enum not documented")
+	}
+	if o := ps.GetByInAndName("path", "org"); o == nil || o.Description != "org id" {
+		t.Fatalf("org description not documented")
+	}
+}
+
+func TestParamMountErrors(t *testing.T) {
+	type tt struct {
+		name  string
+		param Param
+	}
+	tsts := []tt{
+		{
+			name:  "not in path",
+			param: NewPathParam("nope", ParamTypeString),
+		}, {
+			name:  "bad pattern",
+			param: NewPathParam("id", ParamTypeString).WithPattern("("),
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.Get(EndpointConfig{
+				Path:    "/users/:id",
+				Handler: testHandler,
+				Payload: EndpointPayload{
+					ResponsePayload: &testPld{},
+				},
+			}.WithParams(tst.param))
+			if _, err := app.OpenAPI(); err == nil {
+				t.Fatalf("wanted a mount error")
+			}
+		})
+	}
+}
+
+type testHeaders struct {
+	RequestID UUID   `header:"X-Request-Id,required"`
+	Mode      string `header:"X-Mode" enum:"fast,slow" default:"fast"`
+}
+
+type testCookies struct {
+	Session string `cookie:"session,required"`
+	Count   int    `cookie:"count"`
+}
+
+func TestHeaderCookieParams(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(EndpointConfig{
+		Path: "/me",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			var (
+				h testHeaders
+				c testCookies
+			)
+			if err := rc.BindHeader(&h); err != nil {
+				return nil, err
+			}
+			if err := rc.BindCookie(&c); err != nil {
+				return nil, err
+			}
+			return NewString(fmt.Sprintf("%s %s %s %d", h.RequestID, h.Mode, c.Session, c.Count)), nil
+		},
+		Payload: EndpointPayload{
+			ResponsePayload: NewString(""),
+		},
+	}.WithHeaders(testHeaders{}).WithCookies(&testCookies{}).WithParams(
+		NewHeaderParam("X-Tenant", ParamTypeInt).WithDescription("tenant id"),
+	))
+
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	type tt struct {
+		name    string
+		headers map[string]string
+		cookies map[string]string
+		status  int
+		out     string
+	}
+	tsts := []tt{
+		{
+			name:    "valid",
+			headers: map[string]string{"x-request-id": id},
+			cookies: map[string]string{"session": "s", "count": "3"},
+			status:  StatusOK,
+			out:     `"` + id + ` fast s 3"`,
+		}, {
+			name:    "missing header",
+			cookies: map[string]string{"session": "s"},
+			status:  StatusBadRequest,
+		}, {
+			name:    "missing cookie",
+			headers: map[string]string{"X-Request-Id": id},
+			status:  StatusBadRequest,
+		}, {
+			name:    "bad enum",
+			headers: map[string]string{"X-Request-Id": id, "X-Mode": "medium"},
+			cookies: map[string]string{"session": "s"},
+			status:  StatusBadRequest,
+		}, {
+			name:    "bad optional header",
+			headers: map[string]string{"X-Request-Id": id, "X-Tenant": "a"},
+			cookies: map[string]string{"session": "s"},
+			status:  StatusBadRequest,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/me", nil)
+			for k, v := range tst.headers {
+				r.Header.Set(k, v)
+			}
+			for k, v := range tst.cookies {
+				r.AddCookie(&http.Cookie{Name: k, Value: v})
+			}
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := doc.Paths["/me"].Get.Parameters
+	if p := ps.GetByInAndName("header", "X-Request-Id"); p == nil || !p.Required || p.Schema.Value.Pattern != uuidPattern {
+		t.Fatalf("X-Request-Id not documented: %+v", p)
+	}
+	if p := ps.GetByInAndName("header", "X-Mode"); p == nil || p.Required || len(p.Schema.Value.Enum) != 2 {
+		t.Fatalf("X-Mode not documented: %+v", p)
+	}
+	if p := ps.GetByInAndName("header", "X-Tenant"); p == nil || p.Description != "tenant id" {
+		t.Fatalf("X-Tenant not documented: %+v", p)
+	}
+	if p := ps.GetByInAndName("cookie", "count"); p == nil || p.Schema.Value.Type != "integer" {
+		t.Fatalf("count cookie not documented: %+v", p)
+	}
+}
+
+func TestParamInString(t *testing.T) {
+	type tt struct {
+		in  ParamIn
+		out string
+	}
+	tsts := []tt{
+		{PARAM_IN_PATH, "path"},
+		{PARAM_IN_QUERY, "query"},
+		{PARAM_IN_HEADER, "header"},
+		{PARAM_IN_COOKIE, "cookie"},
+		{PARAM_IN_INVALID, ""},
+	}
+	for _, tst := range tsts {
+		if tst.in.String() != tst.out {
+			t.Fatalf("wanted: %q. got: %q", tst.out, tst.in.String())
+		}
+	}
+}
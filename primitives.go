@@ -1,7 +1,8 @@
 package gate
 
 import (
-	json "github.com/goccy/go-json"
+	"encoding/hex"
+	"fmt"
 )
 
 type String string
@@ -16,7 +17,11 @@ func (s String) String() string {
 }
 
 func (s String) Marshal() ([]byte, error) {
-	bs, err := json.Marshal(s.String())
+	return s.encode(JSONCodec{})
+}
+
+func (s String) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(s.String())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -24,8 +29,12 @@ func (s String) Marshal() ([]byte, error) {
 }
 
 func (s *String) Unmarshal(src []byte) error {
+	return s.decode(JSONCodec{}, src)
+}
+
+func (s *String) decode(c Codec, src []byte) error {
 	var v string
-	if err := json.Unmarshal(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*s = String(v)
@@ -48,7 +57,11 @@ func (i Int8) Int8() int8 {
 }
 
 func (i Int8) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(i.Int8())
+	return i.encode(JSONCodec{})
+}
+
+func (i Int8) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(i.Int8())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -56,8 +69,12 @@ func (i Int8) Marshal() ([]byte, error) {
 }
 
 func (i *Int8) Unmarshal(src []byte) error {
+	return i.decode(JSONCodec{}, src)
+}
+
+func (i *Int8) decode(c Codec, src []byte) error {
 	var v int8
-	if err := json.UnmarshalNoEscape(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*i = Int8(v)
@@ -80,7 +97,11 @@ func (i Int) Int() int {
 }
 
 func (i Int) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(i.Int())
+	return i.encode(JSONCodec{})
+}
+
+func (i Int) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(i.Int())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -88,8 +109,12 @@ func (i Int) Marshal() ([]byte, error) {
 }
 
 func (i *Int) Unmarshal(src []byte) error {
+	return i.decode(JSONCodec{}, src)
+}
+
+func (i *Int) decode(c Codec, src []byte) error {
 	var v int
-	if err := json.UnmarshalNoEscape(src, &i); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*i = Int(v)
@@ -111,7 +136,11 @@ func (i Int64) Int64() int64 {
 }
 
 func (i Int64) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(i.Int64())
+	return i.encode(JSONCodec{})
+}
+
+func (i Int64) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(i.Int64())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -119,8 +148,12 @@ func (i Int64) Marshal() ([]byte, error) {
 }
 
 func (i *Int64) Unmarshal(src []byte) error {
+	return i.decode(JSONCodec{}, src)
+}
+
+func (i *Int64) decode(c Codec, src []byte) error {
 	var v int64
-	if err := json.UnmarshalNoEscape(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*i = Int64(v)
@@ -143,7 +176,11 @@ func (u Uint8) Uint8() uint8 {
 }
 
 func (i Uint8) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(i.Uint8())
+	return i.encode(JSONCodec{})
+}
+
+func (i Uint8) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(i.Uint8())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -151,8 +188,12 @@ func (i Uint8) Marshal() ([]byte, error) {
 }
 
 func (i *Uint8) Unmarshal(src []byte) error {
+	return i.decode(JSONCodec{}, src)
+}
+
+func (i *Uint8) decode(c Codec, src []byte) error {
 	var v uint8
-	if err := json.UnmarshalNoEscape(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*i = Uint8(v)
@@ -175,7 +216,11 @@ func (u Uint) Uint() uint {
 }
 
 func (i Uint) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(i.Uint())
+	return i.encode(JSONCodec{})
+}
+
+func (i Uint) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(i.Uint())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -183,8 +228,12 @@ func (i Uint) Marshal() ([]byte, error) {
 }
 
 func (i *Uint) Unmarshal(src []byte) error {
+	return i.decode(JSONCodec{}, src)
+}
+
+func (i *Uint) decode(c Codec, src []byte) error {
 	var v uint
-	if err := json.UnmarshalNoEscape(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*i = Uint(v)
@@ -207,7 +256,11 @@ func (u Uint64) Uint64() uint64 {
 }
 
 func (i Uint64) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(i.Uint64())
+	return i.encode(JSONCodec{})
+}
+
+func (i Uint64) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(i.Uint64())
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -215,8 +268,12 @@ func (i Uint64) Marshal() ([]byte, error) {
 }
 
 func (i *Uint64) Unmarshal(src []byte) error {
+	return i.decode(JSONCodec{}, src)
+}
+
+func (i *Uint64) decode(c Codec, src []byte) error {
 	var v uint64
-	if err := json.UnmarshalNoEscape(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*i = Uint64(v)
@@ -239,7 +296,11 @@ func (b Bool) Bool() bool {
 }
 
 func (b Bool) Marshal() ([]byte, error) {
-	bs, err := json.MarshalNoEscape(b)
+	return b.encode(JSONCodec{})
+}
+
+func (b Bool) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(b)
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -247,8 +308,12 @@ func (b Bool) Marshal() ([]byte, error) {
 }
 
 func (b *Bool) Unmarshal(src []byte) error {
+	return b.decode(JSONCodec{}, src)
+}
+
+func (b *Bool) decode(c Codec, src []byte) error {
 	var v bool
-	if err := json.UnmarshalNoEscape(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*b = Bool(v)
@@ -262,8 +327,12 @@ func (Bool) ContentType() ContentType {
 type HTML string
 
 func (h *HTML) Unmarshal(src []byte) error {
+	return h.decode(JSONCodec{}, src)
+}
+
+func (h *HTML) decode(c Codec, src []byte) error {
 	var v string
-	if err := json.Unmarshal(src, &v); err != nil {
+	if err := c.Decode(src, &v); err != nil {
 		return wrapErr(err)
 	}
 	*h = HTML(v)
@@ -271,7 +340,11 @@ func (h *HTML) Unmarshal(src []byte) error {
 }
 
 func (h HTML) Marshal() ([]byte, error) {
-	bs, err := json.Marshal(string(h))
+	return h.encode(JSONCodec{})
+}
+
+func (h HTML) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(string(h))
 	if err != nil {
 		return nil, wrapErr(err)
 	}
@@ -281,3 +354,76 @@ func (h HTML) Marshal() ([]byte, error) {
 func (HTML) ContentType() ContentType {
 	return "text/html; charset=utf-8"
 }
+
+// A RFC 4122 UUID. It binds from and marshals to the canonical
+// textual form: xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+type UUID [16]byte
+
+const uuidPattern = `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`
+
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, wrapErr(fmt.Errorf("invalid UUID: %q", s))
+	}
+	h := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return u, wrapErr(fmt.Errorf("invalid UUID: %q", s))
+	}
+	return u, nil
+}
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(src []byte) error {
+	v, err := ParseUUID(string(src))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+func (u UUID) Marshal() ([]byte, error) {
+	return u.encode(JSONCodec{})
+}
+
+func (u UUID) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(u.String())
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (u *UUID) Unmarshal(src []byte) error {
+	return u.decode(JSONCodec{}, src)
+}
+
+func (u *UUID) decode(c Codec, src []byte) error {
+	var v string
+	if err := c.Decode(src, &v); err != nil {
+		return wrapErr(err)
+	}
+	return u.UnmarshalText([]byte(v))
+}
+
+func (UUID) ContentType() ContentType {
+	return ContentTypeJSON
+}
@@ -0,0 +1,153 @@
+package gate
+
+import (
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const ContentTypePROBLEM ContentType = "application/problem+json"
+
+const problemComponent = "Problem"
+
+// An RFC 7807 problem details object. Extensions are written as
+// members next to the standard ones.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+func (p Problem) members() map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	if m["type"] == "" {
+		m["type"] = "about:blank"
+	}
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return m
+}
+
+func (p Problem) Marshal() ([]byte, error) {
+	return p.encode(JSONCodec{})
+}
+
+func (p Problem) encode(c Codec) ([]byte, error) {
+	return c.Encode(p.members())
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	return p.Marshal()
+}
+
+func (p *Problem) Unmarshal(src []byte) error {
+	return p.decode(JSONCodec{}, src)
+}
+
+func (p *Problem) decode(c Codec, src []byte) error {
+	m := map[string]interface{}{}
+	if err := c.Decode(src, &m); err != nil {
+		return wrapErr(err)
+	}
+	str := func(k string) string {
+		s, _ := m[k].(string)
+		delete(m, k)
+		return s
+	}
+	*p = Problem{
+		Type:     str("type"),
+		Title:    str("title"),
+		Detail:   str("detail"),
+		Instance: str("instance"),
+	}
+	if f, ok := m["status"].(float64); ok {
+		p.Status = int(f)
+	}
+	delete(m, "status")
+	if len(m) > 0 {
+		p.Extensions = m
+	}
+	return nil
+}
+
+func (p *Problem) UnmarshalJSON(src []byte) error {
+	return p.Unmarshal(src)
+}
+
+func (Problem) ContentType() ContentType {
+	return ContentTypePROBLEM
+}
+
+func (Problem) schema(sg *schemaGen) (*openapi3.SchemaRef, error) {
+	return sg.problemSchema(), nil
+}
+
+// The Problem component. Registered on first use
+func (sg *schemaGen) problemSchema() *openapi3.SchemaRef {
+	if sr, ok := sg.components[problemComponent]; ok {
+		return openapi3.NewSchemaRef(componentRef(problemComponent), sr.Value)
+	}
+	s := openapi3.NewObjectSchema().
+		WithProperty("type", openapi3.NewStringSchema().WithDefault("about:blank")).
+		WithProperty("title", openapi3.NewStringSchema()).
+		WithProperty("status", openapi3.NewIntegerSchema()).
+		WithProperty("detail", openapi3.NewStringSchema()).
+		WithProperty("instance", openapi3.NewStringSchema()).
+		WithAnyAdditionalProperties()
+	s.Required = []string{"type", "title", "status"}
+	sg.components[problemComponent] = openapi3.NewSchemaRef("", s)
+	return openapi3.NewSchemaRef(componentRef(problemComponent), s)
+}
+
+// The problem describing err. See errorHandling.resolve
+func problemFor(r *http.Request, err error) Problem {
+	p := Problem{Status: StatusInternalServerError}
+	switch e := err.(type) {
+	case *ValidationError:
+		p.Status = StatusUnprocessableEntity
+		p.Extensions = map[string]interface{}{"errors": e.Errors}
+	case *Error:
+		p.Status = e.Code
+		p.Type = e.Type
+		p.Instance = e.Instance
+		p.Extensions = e.Extensions
+		p.Detail = e.Error()
+	default:
+		p.Detail = err.Error()
+	}
+	p.Title = httpStatusMessage[p.Status]
+	if p.Detail == p.Title {
+		p.Detail = ""
+	}
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.Path
+	}
+	return p
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, err error, c Codec) error {
+	p := problemFor(r, err)
+	bs, err := p.encode(c)
+	if err != nil {
+		return wrapErr(err)
+	}
+	w.Header().Set(HeaderContentType, ContentTypePROBLEM.String())
+	w.WriteHeader(p.Status)
+	if _, err := w.Write(bs); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package gate
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	json "github.com/goccy/go-json"
+)
+
+type testProblemReq struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func (p testProblemReq) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func (p *testProblemReq) Unmarshal(src []byte) error {
+	return json.Unmarshal(src, p)
+}
+
+func (testProblemReq) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func TestProblemDetails(t *testing.T) {
+	app, err := New(AppOptions{
+		Info:           *newTestApp(t).Info,
+		ProblemDetails: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Post(EndpointConfig{
+		Path: "/users/:id",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			switch rc.Param("id") {
+			case "404":
+				return nil, NewError(StatusNotFound, "no such user").
+					WithType("https://example.com/probs/no-user").
+					WithExtension("id", 404)
+			case "500":
+				return nil, errors.New("database down")
+			}
+			return rd.Body, nil
+		},
+		Payload: EndpointPayload{
+			RequestPayload:  &testProblemReq{},
+			ResponsePayload: &testProblemReq{},
+		},
+	}.WithContentTypes(ContentTypeXML))
+
+	type tt struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		out         map[string]interface{}
+	}
+	tsts := []tt{
+		{
+			name:   "handler error",
+			path:   "/users/404",
+			body:   `{"name":"n"}`,
+			status: StatusNotFound,
+			out: map[string]interface{}{
+				"type":     "https://example.com/probs/no-user",
+				"title":    "Not Found",
+				"status":   float64(StatusNotFound),
+				"detail":   "no such user",
+				"instance": "/users/404",
+				"id":       float64(404),
+			},
+		}, {
+			name:   "plain error",
+			path:   "/users/500",
+			body:   `{"name":"n"}`,
+			status: StatusInternalServerError,
+			out: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Internal Server Error",
+				"status":   float64(StatusInternalServerError),
+				"instance": "/users/500",
+			},
+		}, {
+			name:        "unsupported media type",
+			path:        "/users/1",
+			contentType: "text/csv",
+			body:        "n",
+			status:      StatusUnsupportedMediaType,
+			out: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Unsupported Media Type",
+				"status":   float64(StatusUnsupportedMediaType),
+				"instance": "/users/1",
+			},
+		}, {
+			name:   "validation",
+			path:   "/users/1",
+			body:   `{}`,
+			status: StatusUnprocessableEntity,
+			out: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Unprocessable Entity",
+				"status":   float64(StatusUnprocessableEntity),
+				"instance": "/users/1",
+				"errors": []interface{}{
+					map[string]interface{}{"field": "name", "message": "required"},
+				},
+			},
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tst.path, bytes.NewBufferString(tst.body))
+			if tst.contentType != "" {
+				r.Header.Set(HeaderContentType, tst.contentType)
+			}
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if ct := rw.Header().Get(HeaderContentType); ct != ContentTypePROBLEM.String() {
+				t.Fatalf("wanted content type: %s. got: %s", ContentTypePROBLEM, ct)
+			}
+			got := map[string]interface{}{}
+			if err := json.Unmarshal(rw.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			want, _ := json.Marshal(tst.out)
+			have, _ := json.Marshal(got)
+			if !bytes.Equal(want, have) {
+				t.Fatalf("wanted: %s. got: %s", want, have)
+			}
+
+			var p Problem
+			if err := p.Unmarshal(rw.Body.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != tst.status || p.Title != httpStatusMessage[tst.status] {
+				t.Fatalf("unmarshalled: %+v", p)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Components.Schemas[problemComponent] == nil {
+		t.Fatalf("problem schema not a component")
+	}
+	op := doc.Paths["/users/{id}"].Post
+	for _, code := range []string{"default", "422"} {
+		mt := op.Responses[code].Value.Content.Get(ContentTypePROBLEM.String())
+		if mt == nil || mt.Schema.Ref != componentRef(problemComponent) {
+			t.Fatalf("%s response doesn't reference the problem schema", code)
+		}
+	}
+}
+
+func TestErrorCopies(t *testing.T) {
+	e := ErrNotFound.WithExtension("a", 1).WithType("t")
+	if ErrNotFound.Extensions != nil || ErrNotFound.Type != "" {
+		t.Fatalf("shared error modified")
+	}
+	if e.Extensions["a"] != 1 || e.Type != "t" || e.Code != StatusNotFound {
+		t.Fatalf("got: %+v", e)
+	}
+}
@@ -0,0 +1,218 @@
+package gate
+
+import (
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Wraps a proto.Message as a Payload encoded as ContentTypePROTO.
+// The JSON codecs encode it using the protojson mapping so the same
+// message can be served to REST clients using EndpointConfig.WithCodecs.
+//
+// Endpoints hand every request a fresh message of the sample's type:
+//
+//	gate.NewEndpointPayload(gate.NewProtoContent(&pb.CreateUser{}), nil, gate.NewProtoContent(&pb.User{}))
+type ProtoContent struct {
+	Message proto.Message
+}
+
+func NewProtoContent(m proto.Message) *ProtoContent {
+	return &ProtoContent{Message: m}
+}
+
+func (pc ProtoContent) Marshal() ([]byte, error) {
+	if pc.Message == nil {
+		return nil, nil
+	}
+	bs, err := proto.Marshal(pc.Message)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (pc *ProtoContent) Unmarshal(src []byte) error {
+	if pc.Message == nil {
+		return wrapErr(fmt.Errorf("ProtoContent has no message to unmarshal into"))
+	}
+	if err := proto.Unmarshal(src, pc.Message); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+func (pc ProtoContent) encode(c Codec) ([]byte, error) {
+	if pc.Message == nil {
+		return nil, nil
+	}
+	return c.Encode(pc.Message)
+}
+
+func (pc *ProtoContent) decode(c Codec, src []byte) error {
+	if pc.Message == nil {
+		return wrapErr(fmt.Errorf("ProtoContent has no message to unmarshal into"))
+	}
+	return c.Decode(src, pc.Message)
+}
+
+func (ProtoContent) ContentType() ContentType {
+	return ContentTypePROTO
+}
+
+func (pc ProtoContent) MarshalJSON() ([]byte, error) {
+	if pc.Message == nil {
+		return []byte("null"), nil
+	}
+	return protojson.Marshal(pc.Message)
+}
+
+func (pc *ProtoContent) UnmarshalJSON(src []byte) error {
+	if pc.Message == nil {
+		return wrapErr(fmt.Errorf("ProtoContent has no message to unmarshal into"))
+	}
+	return protojson.Unmarshal(src, pc.Message)
+}
+
+func (pc ProtoContent) newPayload() Payload {
+	if pc.Message == nil {
+		return &ProtoContent{}
+	}
+	return &ProtoContent{Message: pc.Message.ProtoReflect().New().Interface()}
+}
+
+func (pc *ProtoContent) resetPayload() {
+	if pc.Message != nil {
+		proto.Reset(pc.Message)
+	}
+}
+
+func (pc ProtoContent) schema(sg *schemaGen) (*openapi3.SchemaRef, error) {
+	if pc.Message == nil {
+		return openapi3.NewSchemaRef("", openapi3.NewSchema()), nil
+	}
+	return sg.protoMessageSchema(pc.Message.ProtoReflect().Descriptor()), nil
+}
+
+// Builds the schema of md following the protojson mapping.
+// Recursive messages are stored in components like recursive go types.
+func (sg *schemaGen) protoMessageSchema(md protoreflect.MessageDescriptor) *openapi3.SchemaRef {
+	if s, ok := wellKnownSchema(md.FullName()); ok {
+		return openapi3.NewSchemaRef("", s)
+	}
+
+	name := componentNameRegex.ReplaceAllString(string(md.FullName()), "_")
+	if s, ok := sg.protoBuilding[md.FullName()]; ok {
+		sg.protoRecursive[md.FullName()] = true
+		return openapi3.NewSchemaRef(componentRef(name), s)
+	}
+
+	s := openapi3.NewObjectSchema()
+	sg.protoBuilding[md.FullName()] = s
+	defer delete(sg.protoBuilding, md.FullName())
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if s.Properties == nil {
+			s.Properties = openapi3.Schemas{}
+		}
+		s.Properties[fd.JSONName()] = sg.protoFieldSchema(fd)
+	}
+
+	if sg.protoRecursive[md.FullName()] {
+		sg.components[name] = openapi3.NewSchemaRef("", s)
+		return openapi3.NewSchemaRef(componentRef(name), s)
+	}
+	return openapi3.NewSchemaRef("", s)
+}
+
+func (sg *schemaGen) protoFieldSchema(fd protoreflect.FieldDescriptor) *openapi3.SchemaRef {
+	switch {
+	case fd.IsMap():
+		s := openapi3.NewObjectSchema()
+		s.AdditionalProperties = sg.protoValueSchema(fd.MapValue())
+		return openapi3.NewSchemaRef("", s)
+	case fd.IsList():
+		s := openapi3.NewArraySchema()
+		s.Items = sg.protoValueSchema(fd)
+		return openapi3.NewSchemaRef("", s)
+	}
+	return sg.protoValueSchema(fd)
+}
+
+// The schema of a single value of fd
+func (sg *schemaGen) protoValueSchema(fd protoreflect.FieldDescriptor) *openapi3.SchemaRef {
+	var s *openapi3.Schema
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		s = openapi3.NewBoolSchema()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		s = openapi3.NewInt32Schema()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		s = openapi3.NewInt64Schema().WithMin(0).WithMax(1<<32 - 1)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson quotes 64 bit integers
+		s = openapi3.NewStringSchema().WithPattern(`^-?[0-9]+$`)
+	case protoreflect.FloatKind:
+		s = openapi3.NewFloat64Schema().WithFormat("float")
+	case protoreflect.DoubleKind:
+		s = openapi3.NewFloat64Schema().WithFormat("double")
+	case protoreflect.StringKind:
+		s = openapi3.NewStringSchema()
+	case protoreflect.BytesKind:
+		s = openapi3.NewBytesSchema()
+	case protoreflect.EnumKind:
+		s = openapi3.NewStringSchema()
+		vals := fd.Enum().Values()
+		for i := 0; i < vals.Len(); i++ {
+			s.Enum = append(s.Enum, string(vals.Get(i).Name()))
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return sg.protoMessageSchema(fd.Message())
+	default:
+		s = openapi3.NewSchema()
+	}
+	return openapi3.NewSchemaRef("", s)
+}
+
+// Schemas of the well known types which protojson maps specially
+func wellKnownSchema(name protoreflect.FullName) (*openapi3.Schema, bool) {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return openapi3.NewDateTimeSchema(), true
+	case "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return openapi3.NewStringSchema(), true
+	case "google.protobuf.Struct":
+		return openapi3.NewObjectSchema(), true
+	case "google.protobuf.Value":
+		return openapi3.NewSchema(), true
+	case "google.protobuf.ListValue":
+		return openapi3.NewArraySchema().WithItems(openapi3.NewSchema()), true
+	case "google.protobuf.Empty":
+		return openapi3.NewObjectSchema(), true
+	case "google.protobuf.Any":
+		s := openapi3.NewObjectSchema().WithProperty("@type", openapi3.NewStringSchema())
+		s.Required = []string{"@type"}
+		return s, true
+	case "google.protobuf.BoolValue":
+		return openapi3.NewBoolSchema().WithNullable(), true
+	case "google.protobuf.Int32Value":
+		return openapi3.NewInt32Schema().WithNullable(), true
+	case "google.protobuf.UInt32Value":
+		return openapi3.NewInt64Schema().WithMin(0).WithNullable(), true
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return openapi3.NewStringSchema().WithNullable(), true
+	case "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return openapi3.NewFloat64Schema().WithNullable(), true
+	case "google.protobuf.StringValue":
+		return openapi3.NewStringSchema().WithNullable(), true
+	case "google.protobuf.BytesValue":
+		return openapi3.NewBytesSchema().WithNullable(), true
+	}
+	return nil, false
+}
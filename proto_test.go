@@ -0,0 +1,117 @@
+package gate
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestProtoContent(t *testing.T) {
+	app := newTestApp(t)
+	app.Post(EndpointConfig{
+		Path: "/messages",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			in := rd.Body.(*ProtoContent).Message.(*descriptorpb.DescriptorProto)
+			return NewProtoContent(&descriptorpb.DescriptorProto{
+				Name:       proto.String(in.GetName() + "Reply"),
+				NestedType: []*descriptorpb.DescriptorProto{in},
+			}), nil
+		},
+		Payload: NewEndpointPayload(
+			NewProtoContent(&descriptorpb.DescriptorProto{}),
+			nil,
+			NewProtoContent(&descriptorpb.DescriptorProto{}),
+		),
+	}.WithCodecs(JSONCodec{}))
+
+	in := &descriptorpb.DescriptorProto{Name: proto.String("Ping")}
+	bin, err := proto.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := protojson.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type tt struct {
+		name        string
+		contentType ContentType
+		accept      ContentType
+		body        []byte
+	}
+	tsts := []tt{
+		{
+			name:        "proto",
+			contentType: ContentTypePROTO,
+			accept:      ContentTypePROTO,
+			body:        bin,
+		}, {
+			name:        "json",
+			contentType: ContentTypeJSON,
+			accept:      ContentTypeJSON,
+			body:        js,
+		}, {
+			name:        "proto again",
+			contentType: ContentTypePROTO,
+			accept:      ContentTypePROTO,
+			body:        bin,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(tst.body))
+			r.Header.Set(HeaderContentType, tst.contentType.String())
+			r.Header.Set(HeaderAccept, tst.accept.String())
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != StatusOK {
+				t.Fatalf("wanted: 200. got: %d. %s", rw.Code, rw.Body.String())
+			}
+
+			out := &descriptorpb.DescriptorProto{}
+			if tst.accept == ContentTypeJSON {
+				err = protojson.Unmarshal(rw.Body.Bytes(), out)
+			} else {
+				err = proto.Unmarshal(rw.Body.Bytes(), out)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A reused message would carry the previous request's nested types
+			if out.GetName() != "PingReply" || len(out.NestedType) != 1 || len(out.NestedType[0].NestedType) != 0 {
+				t.Fatalf("unexpected reply: %v", out)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := doc.Paths["/messages"].Post.RequestBody.Value.Content
+	if content.Get(ContentTypePROTO.String()) == nil || content.Get(ContentTypeJSON.String()) == nil {
+		t.Fatalf("content types not documented")
+	}
+	comp, ok := doc.Components.Schemas["google.protobuf.DescriptorProto"]
+	if !ok {
+		t.Fatalf("recursive message not in components")
+	}
+	props := comp.Value.Properties
+	if props["nestedType"].Value.Items.Ref == "" {
+		t.Fatalf("nestedType must refer to the component")
+	}
+	field := props["field"].Value.Items.Value.Properties
+	if len(field["type"].Value.Enum) == 0 {
+		t.Fatalf("enum values not documented")
+	}
+	if field["number"].Value.Type != openapi3.TypeInteger {
+		t.Fatalf("int32 not documented as integer")
+	}
+}
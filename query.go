@@ -0,0 +1,354 @@
+package gate
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Implemented by payloads that decode the query string themselves
+type QueryDeserializable interface {
+	UnmarshalQuery(url.Values) error
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// A struct field bound from the query string, path params and so on.
+// The rules are described below for the `query` tag key.
+//
+// The name comes from the `query` tag, falling back to the `json`
+// name and then the field name. `query:"-"` skips the field. Options:
+//
+//	query:"ids,required" - the parameter must be present
+//	default:"10"         - used when the parameter is absent. Slices split it on commas
+//	format:"2006-01-02"  - layout for time.Time fields. Defaults to time.RFC3339
+//	enum:"asc,desc"      - the value must be one of these
+//
+// Pointer fields are left nil when the parameter is absent.
+type tagField struct {
+	index    []int
+	name     string
+	typ      reflect.Type
+	required bool
+	def      []string
+	hasDef   bool
+	format   string
+	enum     []string
+}
+
+type fieldCacheKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var fieldCache sync.Map
+
+func queryFields(typ reflect.Type) []tagField {
+	return tagFields(typ, "query")
+}
+
+// Fields of typ bound using the struct tag key tag. The same rules
+// as the `query` tag apply to every other key.
+func tagFields(typ reflect.Type, key string) []tagField {
+	ck := fieldCacheKey{typ: typ, tag: key}
+	if fs, ok := fieldCache.Load(ck); ok {
+		return fs.([]tagField)
+	}
+	fs := collectTagFields(typ, key, nil)
+	fieldCache.Store(ck, fs)
+	return fs
+}
+
+func collectTagFields(typ reflect.Type, key string, index []int) []tagField {
+	var fs []tagField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, hasTag := f.Tag.Lookup(key)
+		if tag == "-" {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			fs = append(fs, collectTagFields(f.Type, key, idx)...)
+			continue
+		}
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
+			// Allocated when bound. See fieldByIndex
+			if f.IsExported() {
+				fs = append(fs, collectTagFields(f.Type.Elem(), key, idx)...)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			if f.Tag.Get("json") == "-" {
+				continue
+			}
+			name, _, _ = jsonFieldName(f)
+		}
+
+		qf := tagField{
+			index:    idx,
+			name:     name,
+			typ:      f.Type,
+			required: jsonTagOptions(opts).has("required"),
+			format:   f.Tag.Get("format"),
+		}
+		if e, ok := f.Tag.Lookup("enum"); ok {
+			qf.enum = strings.Split(e, ",")
+		}
+		if d, ok := f.Tag.Lookup("default"); ok {
+			qf.hasDef = true
+			qf.def = []string{d}
+			if isSliceField(f.Type) {
+				qf.def = strings.Split(d, ",")
+			}
+		}
+		fs = append(fs, qf)
+	}
+	return fs
+}
+
+// The field of the struct v at index. Nil embedded struct pointers
+// on the way are allocated when alloc is set. Otherwise the zero
+// Value is returned for fields behind them.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func isSliceField(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Slice && !reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// Binds values into the struct dst points to. Every field is visited
+// so the returned *Error lists all the failing fields at once.
+func decodeQuery(values url.Values, dst interface{}) error {
+	return decodeValues(values, dst, "query")
+}
+
+// decodeQuery for any struct tag key
+func decodeValues(values url.Values, dst interface{}, key string) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return wrapErr(fmt.Errorf("%s destination must be a pointer to a struct. got %T", key, dst))
+	}
+	rv = rv.Elem()
+
+	var msgs []string
+	for _, f := range tagFields(rv.Type(), key) {
+		if isFileField(f.typ) {
+			// Bound by MultipartPayload
+			continue
+		}
+		vals := values[f.name]
+		if len(vals) == 0 {
+			switch {
+			case f.hasDef:
+				vals = f.def
+			case f.required:
+				msgs = append(msgs, fmt.Sprintf("%s: required", f.name))
+				continue
+			default:
+				if fv := fieldByIndex(rv, f.index, false); fv.IsValid() {
+					fv.Set(reflect.Zero(fv.Type()))
+				}
+				continue
+			}
+		}
+		fv := fieldByIndex(rv, f.index, true)
+
+		if err := checkEnum(vals, f.enum); err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", f.name, err.Error()))
+			continue
+		}
+		if err := setQueryValue(fv, vals, f.format); err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", f.name, err.Error()))
+		}
+	}
+
+	if len(msgs) > 0 {
+		return NewError(StatusBadRequest, msgs...)
+	}
+	return nil
+}
+
+func checkEnum(vals []string, enum []string) error {
+	if len(enum) == 0 {
+		return nil
+	}
+	for _, v := range vals {
+		found := false
+		for _, e := range enum {
+			if v == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q must be one of %s", v, strings.Join(enum, ", "))
+		}
+	}
+	return nil
+}
+
+func setQueryValue(fv reflect.Value, vals []string, format string) error {
+	if fv.Kind() == reflect.Ptr {
+		v := reflect.New(fv.Type().Elem())
+		if err := setQueryValue(v.Elem(), vals, format); err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	}
+
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		s := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, v := range vals {
+			if err := setQueryScalar(s.Index(i), v, format); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+
+	if len(vals) > 1 {
+		return fmt.Errorf("wanted a single value. got %d", len(vals))
+	}
+	return setQueryScalar(fv, vals[0], format)
+}
+
+func setQueryScalar(fv reflect.Value, s string, format string) error {
+	if fv.Kind() == reflect.Ptr {
+		v := reflect.New(fv.Type().Elem())
+		if err := setQueryScalar(v.Elem(), s, format); err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	}
+
+	switch fv.Type() {
+	case timeType:
+		if format == "" {
+			format = time.RFC3339
+		}
+		t, err := time.Parse(format, s)
+		if err != nil {
+			return fmt.Errorf("invalid time %q. wanted format %s", s, format)
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	if fv.CanAddr() {
+		if tu, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := tu.UnmarshalText([]byte(s)); err != nil {
+				return fmt.Errorf("invalid value %q: %s", s, err.Error())
+			}
+			return nil
+		}
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// Decodes the query string into p. Payloads implementing
+// QueryDeserializable decode themselves. Struct payloads are bound
+// field by field. Anything else receives the query as a JSON object
+// of string arrays encoded using c.
+func unmarshalQuery(values url.Values, p Payload, c Codec) error {
+	if qd, ok := p.(QueryDeserializable); ok {
+		return qd.UnmarshalQuery(values)
+	}
+
+	if typ := payloadType(p); typ.Kind() == reflect.Struct {
+		return decodeQuery(values, p)
+	}
+
+	bs, err := c.Encode(values)
+	if err != nil {
+		return wrapErr(err, "json marshal url query failed")
+	}
+	/*
+	  This only works for Payload types that inherently have a
+	  string->[]string structure. Every other case fails here.
+	*/
+	if cp, ok := p.(codecPayload); ok {
+		err = cp.decode(c, bs)
+	} else {
+		err = p.Unmarshal(bs)
+	}
+	if err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testQueryEmbedded struct {
+	Sort string `query:"sort" default:"asc"`
+}
+
+type testQuery struct {
+	testQueryEmbedded
+	Page    int           `query:"page,required"`
+	Limit   uint8         `query:"limit" default:"20"`
+	Active  *bool         `query:"active"`
+	IDs     []int64       `query:"id"`
+	Tags    []string      `query:"tag" default:"a,b"`
+	Since   time.Time     `query:"since" format:"2006-01-02"`
+	Timeout time.Duration `query:"timeout"`
+	Ratio   float64       `json:"ratio"`
+	Skipped string        `query:"-"`
+}
+
+func (testQuery) Marshal() ([]byte, error) {
+	return nil, nil
+}
+
+func (*testQuery) Unmarshal([]byte) error {
+	return nil
+}
+
+func (testQuery) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func TestDecodeQuery(t *testing.T) {
+	yes := true
+	type tt struct {
+		name   string
+		query  string
+		output testQuery
+		errs   []string
+	}
+
+	tsts := []tt{
+		{
+			name:  "all",
+			query: "page=2&limit=5&active=true&id=1&id=2&tag=x&since=2022-03-04&timeout=2s&ratio=0.5&sort=desc&Skipped=x",
+			output: testQuery{
+				testQueryEmbedded: testQueryEmbedded{Sort: "desc"},
+				Page:              2,
+				Limit:             5,
+				Active:            &yes,
+				IDs:               []int64{1, 2},
+				Tags:              []string{"x"},
+				Since:             time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC),
+				Timeout:           2 * time.Second,
+				Ratio:             0.5,
+			},
+		}, {
+			name:  "defaults",
+			query: "page=1",
+			output: testQuery{
+				testQueryEmbedded: testQueryEmbedded{Sort: "asc"},
+				Page:              1,
+				Limit:             20,
+				Tags:              []string{"a", "b"},
+			},
+		}, {
+			name:  "errors",
+			query: "limit=300&active=maybe&id=1&id=x&since=yesterday&page=1&page=2",
+			errs: []string{
+				"page: wanted a single value. got 2",
+				`limit: invalid unsigned integer "300"`,
+				`active: invalid boolean "maybe"`,
+				`id: invalid integer "x"`,
+				`since: invalid time "yesterday". wanted format 2006-01-02`,
+			},
+		}, {
+			name:  "missing required",
+			query: "limit=1",
+			errs:  []string{"page: required"},
+		},
+	}
+
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			vs, err := url.ParseQuery(tst.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Start dirty to make sure absent fields are reset
+			v := testQuery{Ratio: 9, Skipped: "keep"}
+			err = decodeQuery(vs, &v)
+			if len(tst.errs) > 0 {
+				e, ok := err.(*Error)
+				if !ok {
+					t.Fatalf("wanted *Error. got: %v", err)
+				}
+				if e.Code != StatusBadRequest {
+					t.Fatalf("wanted code: %d. got: %d", StatusBadRequest, e.Code)
+				}
+				if !reflect.DeepEqual(e.Message, tst.errs) {
+					t.Fatalf("wanted: %q\ngot: %q", tst.errs, e.Message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tst.output.Skipped = "keep"
+			if !reflect.DeepEqual(v, tst.output) {
+				t.Fatalf("wanted: %+v\ngot: %+v", tst.output, v)
+			}
+		})
+	}
+}
+
+// Exported so that a nil *EmbeddedCursor can be allocated
+type EmbeddedCursor struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
+func TestDecodeQueryEmbeddedPointer(t *testing.T) {
+	type withCursor struct {
+		*EmbeddedCursor
+		Q string `query:"q"`
+	}
+	type tt struct {
+		name   string
+		query  string
+		output withCursor
+	}
+	tsts := []tt{
+		{
+			name:   "present",
+			query:  "q=x&cursor=abc&limit=5",
+			output: withCursor{EmbeddedCursor: &EmbeddedCursor{Cursor: "abc", Limit: 5}, Q: "x"},
+		}, {
+			name:   "absent",
+			query:  "q=x",
+			output: withCursor{Q: "x"},
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			vs, err := url.ParseQuery(tst.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v withCursor
+			if err := decodeQuery(vs, &v); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, tst.output) {
+				t.Fatalf("wanted: %+v\ngot: %+v", tst.output, v)
+			}
+		})
+	}
+}
+
+func TestQueryBinding(t *testing.T) {
+	app := newTestApp(t)
+	Get(app, "/strict", func(rc *RequestCtx, _ *NoPayload, q *testQuery) (*Int, error) {
+		return NewInt(q.Page), nil
+	})
+	app.Get(NewTypedEndpointConfig(
+		"/lenient",
+		func(rc *RequestCtx, _ *NoPayload, q *testQuery) (*Int, error) {
+			return NewInt(int(q.Limit)), nil
+		},
+	).WithAllowEmptyQuery(true))
+
+	type tt struct {
+		url    string
+		status int
+		body   string
+	}
+	tsts := []tt{
+		{url: "/strict?page=3", status: StatusOK, body: "3"},
+		{url: "/strict", status: StatusBadRequest, body: "empty query params"},
+		{url: "/strict?page=x", status: StatusBadRequest, body: `page: invalid integer "x"`},
+		{url: "/lenient", status: StatusBadRequest, body: "page: required"},
+		{url: "/lenient?page=1", status: StatusOK, body: "20"},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.url, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tst.url, nil))
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if strings.TrimSpace(rw.Body.String()) != tst.body {
+				t.Fatalf("wanted: %s. got: %s", tst.body, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := doc.Paths["/strict"].Get.Parameters
+	if len(ps) != 9 {
+		t.Fatalf("wanted 9 query params. got: %d", len(ps))
+	}
+	limit := ps.GetByInAndName("query", "limit")
+	if limit == nil || limit.Schema.Value.Default != uint8(20) {
+		t.Fatalf("limit default not documented")
+	}
+	if p := ps.GetByInAndName("query", "page"); p == nil || !p.Required {
+		t.Fatalf("page must be required")
+	}
+	if p := ps.GetByInAndName("query", "since"); p == nil || p.Schema.Value.Format != "date" {
+		t.Fatalf("since must be a date")
+	}
+}
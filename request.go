@@ -4,25 +4,36 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
-
-	json "github.com/goccy/go-json"
 )
 
 type QueryPayload url.Values
 
 func (qp QueryPayload) Marshal() ([]byte, error) {
-	return json.Marshal(qp)
+	return qp.encode(JSONCodec{})
+}
+
+func (qp QueryPayload) encode(c Codec) ([]byte, error) {
+	return c.Encode(map[string][]string(qp))
 }
 
 func (qp *QueryPayload) Unmarshal(bs []byte) error {
+	return qp.decode(JSONCodec{}, bs)
+}
+
+func (qp *QueryPayload) decode(c Codec, bs []byte) error {
 	j := map[string][]string{}
-	if err := json.Unmarshal(bs, &j); err != nil {
+	if err := c.Decode(bs, &j); err != nil {
 		return wrapErr(err)
 	}
 	*qp = QueryPayload(j)
 	return nil
 }
 
+func (qp *QueryPayload) UnmarshalQuery(v url.Values) error {
+	*qp = QueryPayload(v)
+	return nil
+}
+
 func (QueryPayload) ContentType() ContentType {
 	return ContentTypeJSON
 }
@@ -44,11 +55,19 @@ func NewJSONContent(d interface{}) (*JSONContent, error) {
 }
 
 func (jc JSONContent) Marshal() ([]byte, error) {
-	return json.Marshal(jc.content)
+	return jc.encode(JSONCodec{})
+}
+
+func (jc JSONContent) encode(c Codec) ([]byte, error) {
+	return c.Encode(jc.content)
 }
 
 func (jc *JSONContent) Unmarshal(bs []byte) error {
-	return json.Unmarshal(bs, &jc.content)
+	return jc.decode(JSONCodec{}, bs)
+}
+
+func (jc *JSONContent) decode(c Codec, bs []byte) error {
+	return c.Decode(bs, &jc.content)
 }
 
 func (JSONContent) ContentType() ContentType {
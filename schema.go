@@ -1,3 +1,375 @@
 package gate
 
-// "github.com/getkin/kin-openapi/openapi3gen"
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonContentType   = reflect.TypeOf(JSONContent{})
+	uuidType          = reflect.TypeOf(UUID{})
+)
+
+var componentNameRegex = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// schemaGen walks go types and builds openapi3 schemas out of them.
+// Schemas are generated inline. The only exception are recursive
+// types which are stored in components and referred to using `$ref`s.
+type schemaGen struct {
+	components openapi3.Schemas
+	names      map[reflect.Type]string
+	taken      map[string]reflect.Type
+	building   map[reflect.Type]*openapi3.Schema
+	recursive  map[reflect.Type]bool
+	// Same as building and recursive for protobuf messages
+	protoBuilding  map[protoreflect.FullName]*openapi3.Schema
+	protoRecursive map[protoreflect.FullName]bool
+}
+
+// Implemented by payloads whose schema can't be derived from their go type
+type schemaProvider interface {
+	schema(sg *schemaGen) (*openapi3.SchemaRef, error)
+}
+
+func newSchemaGen() *schemaGen {
+	return &schemaGen{
+		components: openapi3.Schemas{},
+		names:      map[reflect.Type]string{},
+		taken:      map[string]reflect.Type{},
+		building:   map[reflect.Type]*openapi3.Schema{},
+		recursive:  map[reflect.Type]bool{},
+
+		protoBuilding:  map[protoreflect.FullName]*openapi3.Schema{},
+		protoRecursive: map[protoreflect.FullName]bool{},
+	}
+}
+
+// Returns a component name for typ that is unique within this generator
+// and a valid openapi identifier
+func (sg *schemaGen) componentName(typ reflect.Type) string {
+	if n, ok := sg.names[typ]; ok {
+		return n
+	}
+
+	name := componentNameRegex.ReplaceAllString(typ.Name(), "_")
+	if t, ok := sg.taken[name]; ok && t != typ {
+		pkg := typ.PkgPath()
+		if i := strings.LastIndex(pkg, "/"); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		name = componentNameRegex.ReplaceAllString(pkg, "_") + "." + name
+	}
+
+	base := name
+	for i := 2; ; i++ {
+		t, ok := sg.taken[name]
+		if !ok || t == typ {
+			break
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	sg.names[typ] = name
+	sg.taken[name] = typ
+	return name
+}
+
+func componentRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// Builds the schema for typ. Pointers are dereferenced and marked nullable.
+func (sg *schemaGen) schemaFromType(typ reflect.Type) (*openapi3.SchemaRef, error) {
+	if typ == nil {
+		return openapi3.NewSchemaRef("", openapi3.NewSchema()), nil
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		sr, err := sg.schemaFromType(typ.Elem())
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		if sr.Ref == "" {
+			sr.Value.Nullable = true
+		}
+		return sr, nil
+	}
+
+	switch {
+	case typ == timeType:
+		return openapi3.NewSchemaRef("", openapi3.NewDateTimeSchema()), nil
+	case typ == uuidType:
+		return openapi3.NewSchemaRef("", openapi3.NewStringSchema().WithPattern(uuidPattern)), nil
+	case typ == jsonContentType:
+		// The content of a JSONContent is only known at runtime
+		return openapi3.NewSchemaRef("", openapi3.NewSchema()), nil
+	case typ.Implements(jsonMarshalerType),
+		reflect.PtrTo(typ).Implements(jsonMarshalerType):
+		// Custom JSON representation. Nothing can be said about it.
+		return openapi3.NewSchemaRef("", openapi3.NewSchema()), nil
+	case typ.Implements(textMarshalerType),
+		reflect.PtrTo(typ).Implements(textMarshalerType):
+		return openapi3.NewSchemaRef("", openapi3.NewStringSchema()), nil
+	}
+
+	var s *openapi3.Schema
+	switch typ.Kind() {
+	case reflect.Bool:
+		s = openapi3.NewBoolSchema()
+
+	case reflect.Int8:
+		s = openapi3.NewInt32Schema().WithMin(math.MinInt8).WithMax(math.MaxInt8)
+	case reflect.Int16:
+		s = openapi3.NewInt32Schema().WithMin(math.MinInt16).WithMax(math.MaxInt16)
+	case reflect.Int32:
+		s = openapi3.NewInt32Schema()
+	case reflect.Int, reflect.Int64:
+		s = openapi3.NewInt64Schema()
+
+	case reflect.Uint8:
+		s = openapi3.NewInt32Schema().WithMin(0).WithMax(math.MaxUint8)
+	case reflect.Uint16:
+		s = openapi3.NewInt32Schema().WithMin(0).WithMax(math.MaxUint16)
+	case reflect.Uint32:
+		s = openapi3.NewInt64Schema().WithMin(0).WithMax(math.MaxUint32)
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		s = openapi3.NewInt64Schema().WithMin(0)
+
+	case reflect.Float32:
+		s = openapi3.NewFloat64Schema().WithFormat("float")
+	case reflect.Float64:
+		s = openapi3.NewFloat64Schema().WithFormat("double")
+
+	case reflect.String:
+		s = openapi3.NewStringSchema()
+
+	case reflect.Interface:
+		s = openapi3.NewSchema()
+
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && typ.Kind() == reflect.Slice {
+			// []byte is base64 encoded by encoding/json
+			s = openapi3.NewBytesSchema()
+			break
+		}
+		items, err := sg.schemaFromType(typ.Elem())
+		if err != nil {
+			return nil, wrapErr(err, typ.String())
+		}
+		s = openapi3.NewArraySchema()
+		s.Items = items
+		if typ.Kind() == reflect.Array {
+			s.WithMinItems(int64(typ.Len())).WithMaxItems(int64(typ.Len()))
+		} else {
+			s.Nullable = true
+		}
+
+	case reflect.Map:
+		if !validMapKey(typ.Key()) {
+			return nil, wrapErr(fmt.Errorf("unsupported map key type: %s", typ.Key()))
+		}
+		ap, err := sg.schemaFromType(typ.Elem())
+		if err != nil {
+			return nil, wrapErr(err, typ.String())
+		}
+		s = openapi3.NewObjectSchema()
+		s.AdditionalProperties = ap
+		s.Nullable = true
+
+	case reflect.Struct:
+		return sg.structSchema(typ)
+
+	default:
+		return nil, wrapErr(fmt.Errorf("unsupported type: %s", typ))
+	}
+	return openapi3.NewSchemaRef("", s), nil
+}
+
+func validMapKey(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		return true
+	}
+	return typ.Implements(textMarshalerType) ||
+		reflect.PtrTo(typ).Implements(textMarshalerType)
+}
+
+func (sg *schemaGen) structSchema(typ reflect.Type) (*openapi3.SchemaRef, error) {
+	if s, ok := sg.building[typ]; ok {
+		// We're inside typ already. Refer to it instead of recursing forever.
+		sg.recursive[typ] = true
+		return openapi3.NewSchemaRef(componentRef(sg.componentName(typ)), s), nil
+	}
+
+	s := openapi3.NewObjectSchema()
+	if typ.Name() != "" {
+		sg.building[typ] = s
+		defer delete(sg.building, typ)
+	}
+
+	if err := sg.structFields(s, typ); err != nil {
+		return nil, wrapErr(err, typ.String())
+	}
+
+	if sg.recursive[typ] {
+		name := sg.componentName(typ)
+		sg.components[name] = openapi3.NewSchemaRef("", s)
+		return openapi3.NewSchemaRef(componentRef(name), s), nil
+	}
+	return openapi3.NewSchemaRef("", s), nil
+}
+
+type jsonTagOptions string
+
+func (o jsonTagOptions) has(opt string) bool {
+	for _, v := range strings.Split(string(o), ",") {
+		if v == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the json name, the tag options and whether the tag
+// provided an explicit name
+func jsonFieldName(f reflect.StructField) (string, jsonTagOptions, bool) {
+	tag := f.Tag.Get("json")
+	name, opts := tag, ""
+	if i := strings.Index(tag, ","); i >= 0 {
+		name, opts = tag[:i], tag[i+1:]
+	}
+	if name == "" {
+		return f.Name, jsonTagOptions(opts), false
+	}
+	return name, jsonTagOptions(opts), true
+}
+
+// Adds the fields of typ to s. Fields of embedded structs are promoted
+// the way encoding/json promotes them: shallower fields win.
+func (sg *schemaGen) structFields(s *openapi3.Schema, typ reflect.Type) error {
+	seen := map[string]bool{}
+	visited := map[reflect.Type]bool{typ: true}
+	for level := []reflect.Type{typ}; len(level) > 0; {
+		var next []reflect.Type
+		for _, t := range level {
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if f.Tag.Get("json") == "-" {
+					continue
+				}
+				name, opts, named := jsonFieldName(f)
+
+				ft := f.Type
+				if f.Anonymous && !named {
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						if !visited[ft] {
+							visited[ft] = true
+							next = append(next, ft)
+						}
+						continue
+					}
+				}
+
+				if !f.IsExported() || seen[name] {
+					continue
+				}
+				seen[name] = true
+
+				var (
+					sr  *openapi3.SchemaRef
+					err error
+				)
+				if opts.has("string") && stringableKind(ft) {
+					sr = openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+				} else {
+					sr, err = sg.schemaFromType(ft)
+					if err != nil {
+						return wrapErr(err, f.Name)
+					}
+				}
+
+				fr, err := parseFieldRules(f)
+				if err != nil {
+					return wrapErr(err, f.Name)
+				}
+				if sr.Ref == "" && !fr.empty() {
+					fr.applySchema(sr.Value, ft)
+				}
+
+				if s.Properties == nil {
+					s.Properties = openapi3.Schemas{}
+				}
+				s.Properties[name] = sr
+				if !opts.has("omitempty") || fr.required {
+					s.Required = append(s.Required, name)
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// Kinds encoding/json quotes when the `string` tag option is present
+func stringableKind(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Builds the schema of the payload p
+func (sg *schemaGen) payloadSchema(p Payload) (*openapi3.SchemaRef, error) {
+	if sp, ok := p.(schemaProvider); ok {
+		return sp.schema(sg)
+	}
+	return sg.schemaFromType(payloadType(p))
+}
+
+// Generates a standalone schema for typ. Any recursive types
+// encountered are returned as components alongside.
+func schemaFromType(typ reflect.Type) (*openapi3.SchemaRef, openapi3.Schemas, error) {
+	sg := newSchemaGen()
+	sr, err := sg.schemaFromType(typ)
+	if err != nil {
+		return nil, nil, wrapErr(err)
+	}
+	return sr, sg.components, nil
+}
+
+// Payloads are usually handed to gate as pointers. The pointer
+// is only a vehicle so the schema is generated for the value type.
+func payloadType(p Payload) reflect.Type {
+	if p == nil {
+		return nil
+	}
+	typ := reflect.TypeOf(p)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,189 @@
+package gate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+type testSchemaBase struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created"`
+	Name    string    `json:"base_name"`
+}
+
+type testSchemaPld struct {
+	testSchemaBase
+	Name     string           `json:"name"`
+	Nick     *string          `json:"nick,omitempty"`
+	Tags     []String         `json:"tags"`
+	Meta     map[string]Int64 `json:"meta,omitempty"`
+	Count    int              `json:"count,string"`
+	Skipped  string           `json:"-"`
+	Page     Pagination       `json:"page"`
+	Children map[string]*HTML `json:"children"`
+	Fixed    [2]Bool          `json:"fixed"`
+	Raw      []byte           `json:"raw"`
+	Any      interface{}      `json:"any"`
+	Untagged uint8
+	hidden   string
+}
+
+type testSchemaNode struct {
+	Value    string            `json:"value"`
+	Children []*testSchemaNode `json:"children"`
+}
+
+func TestSchemaFromType(t *testing.T) {
+	type tt struct {
+		name   string
+		input  reflect.Type
+		check  func(*testing.T, *openapi3.SchemaRef, openapi3.Schemas)
+		hasErr bool
+	}
+
+	typeIs := func(t *testing.T, sr *openapi3.SchemaRef, typ, format string) {
+		t.Helper()
+		if sr.Value.Type != typ {
+			t.Fatalf("wanted type: %s. got: %s", typ, sr.Value.Type)
+		}
+		if sr.Value.Format != format {
+			t.Fatalf("wanted format: %s. got: %s", format, sr.Value.Format)
+		}
+	}
+
+	tsts := []tt{
+		{
+			name:  "String",
+			input: payloadType(NewString("")),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, _ openapi3.Schemas) {
+				typeIs(t, sr, openapi3.TypeString, "")
+			},
+		}, {
+			name:  "Int64",
+			input: payloadType(NewInt64(0)),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, _ openapi3.Schemas) {
+				typeIs(t, sr, openapi3.TypeInteger, "int64")
+			},
+		}, {
+			name:  "Uint8",
+			input: payloadType(NewUint8(0)),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, _ openapi3.Schemas) {
+				typeIs(t, sr, openapi3.TypeInteger, "int32")
+				if *sr.Value.Min != 0 || *sr.Value.Max != 255 {
+					t.Fatalf("wanted range 0-255. got: %v-%v", *sr.Value.Min, *sr.Value.Max)
+				}
+			},
+		}, {
+			name:  "Bool",
+			input: payloadType(NewBool(false)),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, _ openapi3.Schemas) {
+				typeIs(t, sr, openapi3.TypeBoolean, "")
+			},
+		}, {
+			name:  "QueryPayload",
+			input: payloadType(&QueryPayload{}),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, _ openapi3.Schemas) {
+				typeIs(t, sr, openapi3.TypeObject, "")
+				ap := sr.Value.AdditionalProperties
+				if ap == nil || ap.Value.Type != openapi3.TypeArray {
+					t.Fatalf("wanted additionalProperties of type array")
+				}
+				typeIs(t, ap.Value.Items, openapi3.TypeString, "")
+			},
+		}, {
+			name:  "struct",
+			input: reflect.TypeOf(testSchemaPld{}),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, _ openapi3.Schemas) {
+				typeIs(t, sr, openapi3.TypeObject, "")
+				props := sr.Value.Properties
+				want := map[string][2]string{
+					"id":        {openapi3.TypeInteger, "int64"},
+					"created":   {openapi3.TypeString, "date-time"},
+					"base_name": {openapi3.TypeString, ""},
+					"name":      {openapi3.TypeString, ""},
+					"nick":      {openapi3.TypeString, ""},
+					"tags":      {openapi3.TypeArray, ""},
+					"meta":      {openapi3.TypeObject, ""},
+					"count":     {openapi3.TypeString, ""},
+					"page":      {openapi3.TypeObject, ""},
+					"children":  {openapi3.TypeObject, ""},
+					"fixed":     {openapi3.TypeArray, ""},
+					"raw":       {openapi3.TypeString, "byte"},
+					"any":       {"", ""},
+					"Untagged":  {openapi3.TypeInteger, "int32"},
+				}
+				if len(props) != len(want) {
+					t.Fatalf("wanted %d properties. got %d", len(want), len(props))
+				}
+				for k, v := range want {
+					p, ok := props[k]
+					if !ok {
+						t.Fatalf("property %s missing", k)
+					}
+					typeIs(t, p, v[0], v[1])
+				}
+				if !props["nick"].Value.Nullable {
+					t.Fatalf("pointer field must be nullable")
+				}
+				if l := len(props["page"].Value.Properties); l != 5 {
+					t.Fatalf("wanted 5 pagination properties. got: %d", l)
+				}
+				for _, r := range sr.Value.Required {
+					if r == "nick" || r == "meta" {
+						t.Fatalf("omitempty field %s marked required", r)
+					}
+				}
+				if len(sr.Value.Required) != len(want)-2 {
+					t.Fatalf("wanted %d required fields. got: %v", len(want)-2, sr.Value.Required)
+				}
+			},
+		}, {
+			name:  "recursive",
+			input: reflect.TypeOf(testSchemaNode{}),
+			check: func(t *testing.T, sr *openapi3.SchemaRef, cs openapi3.Schemas) {
+				ref := "#/components/schemas/testSchemaNode"
+				if sr.Ref != ref {
+					t.Fatalf("wanted ref: %s. got: %s", ref, sr.Ref)
+				}
+				if _, ok := cs["testSchemaNode"]; !ok {
+					t.Fatalf("component missing")
+				}
+				items := sr.Value.Properties["children"].Value.Items
+				if items.Ref != ref {
+					t.Fatalf("wanted items ref: %s. got: %s", ref, items.Ref)
+				}
+			},
+		}, {
+			name:   "chan",
+			input:  reflect.TypeOf(make(chan int)),
+			hasErr: true,
+		}, {
+			name:   "invalid map key",
+			input:  reflect.TypeOf(map[bool]string{}),
+			hasErr: true,
+		},
+	}
+
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			sr, cs, err := schemaFromType(tst.input)
+			if tst.hasErr {
+				if err == nil {
+					t.Fatalf("wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sr.Validate(context.TODO()); err != nil {
+				t.Fatalf("invalid schema: %s", err.Error())
+			}
+			tst.check(t, sr, cs)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package gate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentTypeEventStream ContentType = "text/event-stream"
+
+const defaultKeepAlive = 15 * time.Second
+
+// A single Server-Sent Event. Empty fields are left out.
+// Data is sent as is when it's a string or []byte and as JSON otherwise.
+// Multi-line data is split over several data fields.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+func (ev SSEEvent) encode(c Codec) ([]byte, error) {
+	if strings.ContainsAny(ev.ID, "\r\n\x00") {
+		return nil, wrapErr(fmt.Errorf("event id can't contain newlines or NUL"))
+	}
+	if strings.ContainsAny(ev.Event, "\r\n") {
+		return nil, wrapErr(fmt.Errorf("event name can't contain newlines"))
+	}
+
+	var data string
+	switch d := ev.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		bs, err := streamJSON(c, d)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		data = string(bs)
+	}
+
+	var buf bytes.Buffer
+	if ev.ID != "" {
+		buf.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	if ev.Data != nil {
+		data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+		for _, line := range strings.Split(data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// Sends events to the client until it returns. lastEventID is the
+// Last-Event-ID a reconnecting client sent and is empty otherwise.
+//
+// The handler must return once RequestCtx.Context is done. Events
+// sent after the client went away are dropped. Closing events ends
+// the stream like returning does.
+type SSEHandler func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error
+
+type sseSpec struct {
+	handler   SSEHandler
+	data      Payload
+	keepAlive time.Duration
+}
+
+// Creates an EndpointConfig for an SSEHandler. Register it using
+// App.SSE. data documents the Data of the events in the OpenAPI
+// document and may be nil.
+func NewSSEEndpointConfig(path string, data Payload, h SSEHandler) EndpointConfig {
+	return EndpointConfig{
+		Path: path,
+		sse: &sseSpec{
+			handler:   h,
+			data:      data,
+			keepAlive: defaultKeepAlive,
+		},
+	}
+}
+
+// How often a comment is sent while no events are to keep
+// proxies from closing the connection. Defaults to 15 seconds.
+// Zero or less turns keep-alives off.
+func (ec EndpointConfig) WithKeepAlive(d time.Duration) EndpointConfig {
+	if ec.sse != nil {
+		s := *ec.sse
+		s.keepAlive = d
+		ec.sse = &s
+	}
+	return ec
+}
+
+// Add a GET endpoint serving Server-Sent Events.
+// ec must be created using NewSSEEndpointConfig. The handler runs
+// through the app's middlewares like any other.
+func (app *App) SSE(ec EndpointConfig) {
+	ec.method = http.MethodGet
+	if ec.sse == nil {
+		ec.err = fmt.Errorf("SSE endpoints must be created using NewSSEEndpointConfig")
+	} else {
+		ec.stream = &streamSpec{contentType: ContentTypeEventStream, item: ec.sse.data}
+		ec.Handler = ec.sse.handle
+	}
+	app.registerEndpoint(
+		ec, app.router.GET,
+	)
+}
+
+func (s *sseSpec) handle(rc *RequestCtx, rd *RequestData) (Payload, error) {
+	h := rc.ResponseWriter.Header()
+	h.Set(HeaderCacheControl, "no-cache")
+	// Keeps nginx from buffering the stream
+	h.Set("X-Accel-Buffering", "no")
+
+	sw := newStreamWriter(rc, ContentTypeEventStream)
+	events := make(chan SSEEvent)
+	done := make(chan error, 1)
+	go func() {
+		// Panics are raised again on the request's goroutine
+		// for the app's panic handling
+		defer func() {
+			if v := recover(); v != nil {
+				done <- ssePanic{v}
+			}
+		}()
+		done <- s.handler(rc, rc.Request.Header.Get(HeaderLastEventID), events)
+	}()
+
+	var tick <-chan time.Time
+	if s.keepAlive > 0 {
+		t := time.NewTicker(s.keepAlive)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	// Drops events until the handler returns
+	drain := func() error {
+		evs := events
+		for {
+			select {
+			case _, ok := <-evs:
+				if !ok {
+					evs = nil
+				}
+			case err := <-done:
+				return err
+			}
+		}
+	}
+
+	var err error
+loop:
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				err = <-done
+				break loop
+			}
+			bs, e := ev.encode(rc.jsonCodec())
+			if e != nil {
+				log.Println(wrapErr(e, "sse"))
+				continue
+			}
+			if _, e := sw.Write(bs); e != nil {
+				err = drain()
+				break loop
+			}
+		case <-tick:
+			if _, e := sw.Write([]byte(": keep-alive\n\n")); e != nil {
+				err = drain()
+				break loop
+			}
+		case err = <-done:
+			break loop
+		case <-rc.Context().Done():
+			err = drain()
+			break loop
+		}
+	}
+
+	if p, ok := err.(ssePanic); ok {
+		panic(p.v)
+	}
+	if err != nil && !sw.started() {
+		return nil, err
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Println(wrapErr(err, "sse"))
+	}
+	sw.Close()
+	return nil, nil
+}
+
+// A panic recovered from an SSEHandler
+type ssePanic struct {
+	v interface{}
+}
+
+func (p ssePanic) Error() string {
+	return fmt.Sprintf("sse handler panic: %v", p.v)
+}
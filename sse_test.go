@@ -0,0 +1,194 @@
+package gate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(&Middleware{
+		ID: "tag",
+		Handler: func(h Handler) Handler {
+			return func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+				rc.ResponseWriter.Header().Set("X-Middleware", "yes")
+				return h(rc, rd)
+			}
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	app.SSE(NewSSEEndpointConfig("/events", &testPld{}, func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error {
+		if lastEventID == "" {
+			events <- SSEEvent{ID: "1", Event: "greeting", Data: "hello\nworld", Retry: time.Second}
+		}
+		events <- SSEEvent{ID: "2", Data: &testPld{Key: "k", Value: "v"}}
+		return nil
+	}))
+	app.SSE(NewSSEEndpointConfig("/idle", nil, func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error {
+		time.Sleep(30 * time.Millisecond)
+		close(events)
+		return nil
+	}).WithKeepAlive(5 * time.Millisecond))
+	app.SSE(NewSSEEndpointConfig("/forbidden", nil, func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error {
+		return NewError(StatusForbidden)
+	}))
+
+	type tt struct {
+		name        string
+		path        string
+		lastEventID string
+		status      int
+		out         string
+		contains    string
+	}
+	tsts := []tt{
+		{
+			name:   "events",
+			path:   "/events",
+			status: StatusOK,
+			out: "id: 1\nevent: greeting\nretry: 1000\ndata: hello\ndata: world\n\n" +
+				"id: 2\ndata: {\"key\":\"k\",\"value\":\"v\"}\n\n",
+		}, {
+			name:        "resumed",
+			path:        "/events",
+			lastEventID: "1",
+			status:      StatusOK,
+			out:         "id: 2\ndata: {\"key\":\"k\",\"value\":\"v\"}\n\n",
+		}, {
+			name:     "keep alive",
+			path:     "/idle",
+			status:   StatusOK,
+			contains: ": keep-alive\n\n",
+		}, {
+			name:   "error before sending",
+			path:   "/forbidden",
+			status: StatusForbidden,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tst.path, nil)
+			if tst.lastEventID != "" {
+				r.Header.Set(HeaderLastEventID, tst.lastEventID)
+			}
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if rw.Header().Get("X-Middleware") != "yes" {
+				t.Fatalf("middleware didn't run")
+			}
+			if tst.status != StatusOK {
+				return
+			}
+			if ct := rw.Header().Get(HeaderContentType); ct != ContentTypeEventStream.String() {
+				t.Fatalf("wanted content type: %s. got: %s", ContentTypeEventStream, ct)
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+			if !strings.Contains(rw.Body.String(), tst.contains) {
+				t.Fatalf("wanted %q in: %q", tst.contains, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := doc.Paths["/events"].Get.Responses.Get(StatusOK).Value
+	if mt := res.Content.Get(ContentTypeEventStream.String()); mt == nil || mt.Schema.Value.Properties["key"] == nil {
+		t.Fatalf("event data not documented")
+	}
+}
+
+func TestSSEDisconnect(t *testing.T) {
+	app := newTestApp(t)
+	returned := make(chan struct{})
+	app.SSE(NewSSEEndpointConfig("/events", nil, func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error {
+		defer close(returned)
+		for {
+			select {
+			case <-rc.Context().Done():
+				return rc.Context().Err()
+			case events <- SSEEvent{Data: "tick"}:
+			}
+		}
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	rw := httptest.NewRecorder()
+	app.ServeHTTP(rw, r)
+	select {
+	case <-returned:
+	default:
+		t.Fatalf("handler still running after the client left")
+	}
+	if !strings.HasPrefix(rw.Body.String(), "data: tick\n\n") {
+		t.Fatalf("got: %q", rw.Body.String())
+	}
+
+	app = newTestApp(t)
+	app.SSE(NewEndpointConfig("/plain", testHandler))
+	if _, err := app.OpenAPI(); err == nil {
+		t.Fatalf("SSE endpoint without an SSEHandler mounted")
+	}
+}
+
+func TestSSEHandlerPanic(t *testing.T) {
+	app := newTestApp(t)
+	recovered := make(chan interface{}, 1)
+	if err := app.SetPanicHandler(func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		recovered <- v
+	}); err != nil {
+		t.Fatal(err)
+	}
+	app.SSE(NewSSEEndpointConfig("/boom", nil, func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error {
+		events <- SSEEvent{Data: "tick"}
+		panic("boom")
+	}))
+	app.SSE(NewSSEEndpointConfig("/events", nil, func(rc *RequestCtx, lastEventID string, events chan<- SSEEvent) error {
+		events <- SSEEvent{Data: "tick"}
+		return nil
+	}))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/boom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	select {
+	case v := <-recovered:
+		if v != "boom" {
+			t.Fatalf("wanted: boom. got: %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("panic handler not called")
+	}
+
+	res, err = http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "data: tick\n\n" {
+		t.Fatalf("got: %q", bs)
+	}
+}
@@ -0,0 +1,228 @@
+package gate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type StaticOptions struct {
+	// Served for requests to a directory. Defaults to "index.html"
+	Index string
+	// Serve the root Index for paths matching no file, so that
+	// a single page application can route them itself
+	SPA bool
+	// Cache-Control header sent with every file. None when empty
+	CacheControl string
+	// Cache-Control header sent with Index files, including SPA
+	// fallbacks. Defaults to CacheControl
+	IndexCacheControl string
+	// Serve "name.br" or "name.gz" in place of "name" when they
+	// exist and the client accepts the encoding
+	Precompressed bool
+}
+
+// Precompressed siblings in order of preference
+var staticEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type staticFiles struct {
+	fsys fs.FS
+	opts StaticOptions
+	// Serves what fsys doesn't have. The not found handler
+	// replaced by a "/" prefix
+	next http.Handler
+	// Content hashes of files without a modification time.
+	// These come from immutable file systems such as embed.FS
+	etags sync.Map
+}
+
+// Serves the files of fsys under prefix using GET and HEAD. Content
+// types are picked by extension. ETag and Last-Modified headers are
+// sent and conditional and range requests honoured. Files aren't
+// documented. An empty or "/" prefix serves fsys for every path no
+// other route matches. Paths matching no file then go to the not
+// found handler set before, if any, such as another "/" Static.
+func (app *App) Static(prefix string, fsys fs.FS, opts StaticOptions) {
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+	if opts.IndexCacheControl == "" {
+		opts.IndexCacheControl = opts.CacheControl
+	}
+	sf := &staticFiles{fsys: fsys, opts: opts}
+	var err error
+	if fsys == nil {
+		err = fmt.Errorf("nil file system served at %q", prefix)
+	}
+
+	prefix = cleanPrefix(prefix)
+	if prefix != "" {
+		app.mount(prefix, []string{http.MethodGet, http.MethodHead}, sf.handle, err)
+		return
+	}
+
+	// A catch-all on "/" would conflict with every other route
+	ec := NewEndpointConfig("/*"+mountParam, sf.handle)
+	ec.method = http.MethodGet
+	ec.err = err
+	ec.undocumented = true
+	app.registerEndpoint(ec, func(_ string, h httprouter.Handle) {
+		sf.next = app.router.NotFound
+		app.router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h(w, r, httprouter.Params{{Key: mountParam, Value: r.URL.Path}})
+		})
+	})
+}
+
+func (sf *staticFiles) handle(rc *RequestCtx, rd *RequestData) (Payload, error) {
+	r := rc.Request
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return sf.notFound(rc)
+	}
+
+	name, fi, err := sf.stat(rc.Param(mountParam))
+	index := err == nil && path.Base(name) == sf.opts.Index
+	if errors.Is(err, fs.ErrNotExist) && sf.opts.SPA {
+		name, fi, err = sf.stat("/")
+		index = true
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return sf.notFound(rc)
+	}
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	h := rc.ResponseWriter.Header()
+	served, sfi := name, fi
+	if sf.opts.Precompressed {
+		h.Add(HeaderVary, HeaderAcceptEncoding)
+		for _, e := range staticEncodings {
+			if !acceptsEncoding(r.Header.Get(HeaderAcceptEncoding), e.encoding) {
+				continue
+			}
+			if cfi, err := fs.Stat(sf.fsys, name+e.ext); err == nil && !cfi.IsDir() {
+				served, sfi = name+e.ext, cfi
+				h.Set(HeaderContentEncoding, e.encoding)
+				break
+			}
+		}
+	}
+
+	f, err := sf.fsys.Open(served)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		bs, err := io.ReadAll(f)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		content = bytes.NewReader(bs)
+	}
+
+	etag, err := sf.etag(served, sfi, content)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	h.Set(HeaderETag, etag)
+
+	ct, ok := mimeExtensions[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))]
+	if !ok && served != name {
+		// Sniffing would look at the compressed bytes
+		ct = MIMEOctetStream
+	}
+	if ct != "" {
+		h.Set(HeaderContentType, ct)
+	}
+	cc := sf.opts.CacheControl
+	if index {
+		cc = sf.opts.IndexCacheControl
+	}
+	if cc != "" {
+		h.Set(HeaderCacheControl, cc)
+	}
+
+	http.ServeContent(rc.ResponseWriter, r, name, sfi.ModTime(), content)
+	return nil, nil
+}
+
+func (sf *staticFiles) notFound(rc *RequestCtx) (Payload, error) {
+	if sf.next == nil {
+		return nil, ErrNotFound
+	}
+	sf.next.ServeHTTP(rc.ResponseWriter, rc.Request)
+	return nil, nil
+}
+
+// Resolves the request path p to a file in fsys. Directories
+// resolve to their Index
+func (sf *staticFiles) stat(p string) (string, fs.FileInfo, error) {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	fi, err := fs.Stat(sf.fsys, name)
+	if err == nil && fi.IsDir() {
+		name = path.Join(name, sf.opts.Index)
+		fi, err = fs.Stat(sf.fsys, name)
+	}
+	if err == nil && fi.IsDir() {
+		err = fs.ErrNotExist
+	}
+	if err != nil && (errors.Is(err, fs.ErrInvalid) || errors.Is(err, fs.ErrPermission)) {
+		err = fs.ErrNotExist
+	}
+	return name, fi, err
+}
+
+// A strong ETag for the file. Built from the size and modification
+// time when there's one, from a hash of the content otherwise.
+func (sf *staticFiles) etag(name string, fi fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()), nil
+	}
+	if v, ok := sf.etags.Load(name); ok {
+		return v.(string), nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", wrapErr(err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", wrapErr(err)
+	}
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+	sf.etags.Store(name, etag)
+	return etag, nil
+}
+
+// Reports whether an Accept-Encoding header value allows enc
+func acceptsEncoding(header, enc string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), enc) {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return !strings.HasPrefix(q, "q=0") || strings.Trim(strings.TrimPrefix(q, "q="), "0.") != ""
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package gate
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStatic(t *testing.T) {
+	mod := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":       {Data: []byte("<html>home</html>"), ModTime: mod},
+		"app.js":           {Data: []byte("console.log(1)"), ModTime: mod},
+		"app.js.br":        {Data: []byte("br bytes"), ModTime: mod},
+		"app.js.gz":        {Data: []byte("gz bytes"), ModTime: mod},
+		"docs/index.html":  {Data: []byte("<html>docs</html>"), ModTime: mod},
+		"data/report.txt":  {Data: []byte("0123456789"), ModTime: mod},
+		"data/unknown.zzz": {Data: []byte("??")},
+	}
+
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/api/users", testHandler))
+	app.Static("/assets", fsys, StaticOptions{
+		CacheControl:  "public, max-age=31536000, immutable",
+		Precompressed: true,
+	})
+	app.Static("/", fsys, StaticOptions{
+		SPA:               true,
+		IndexCacheControl: "no-cache",
+	})
+
+	etag := fmt.Sprintf(`"%x-%x"`, mod.UnixNano(), len("console.log(1)"))
+	sum := sha256.Sum256([]byte("??"))
+	type tt struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+		out     string
+		want    map[string]string
+	}
+	tsts := []tt{
+		{
+			name:   "file",
+			path:   "/assets/app.js",
+			status: StatusOK,
+			out:    "console.log(1)",
+			want: map[string]string{
+				HeaderContentType:  "application/javascript",
+				HeaderETag:         etag,
+				HeaderLastModified: mod.Format(http.TimeFormat),
+				HeaderCacheControl: "public, max-age=31536000, immutable",
+				HeaderVary:         HeaderAcceptEncoding,
+			},
+		}, {
+			name:    "brotli",
+			path:    "/assets/app.js",
+			headers: map[string]string{HeaderAcceptEncoding: "gzip, br"},
+			status:  StatusOK,
+			out:     "br bytes",
+			want: map[string]string{
+				HeaderContentType:     "application/javascript",
+				HeaderContentEncoding: "br",
+			},
+		}, {
+			name:    "gzip",
+			path:    "/assets/app.js",
+			headers: map[string]string{HeaderAcceptEncoding: "gzip, br;q=0"},
+			status:  StatusOK,
+			out:     "gz bytes",
+			want:    map[string]string{HeaderContentEncoding: "gzip"},
+		}, {
+			name:    "if none match",
+			path:    "/assets/app.js",
+			headers: map[string]string{HeaderIfNoneMatch: etag},
+			status:  StatusNotModified,
+		}, {
+			name:    "if modified since",
+			path:    "/assets/app.js",
+			headers: map[string]string{HeaderIfModifiedSince: mod.Format(http.TimeFormat)},
+			status:  StatusNotModified,
+		}, {
+			name:    "range",
+			path:    "/assets/data/report.txt",
+			headers: map[string]string{HeaderRange: "bytes=2-4"},
+			status:  StatusPartialContent,
+			out:     "234",
+			want:    map[string]string{HeaderContentRange: "bytes 2-4/10"},
+		}, {
+			name:   "head",
+			method: http.MethodHead,
+			path:   "/assets/data/report.txt",
+			status: StatusOK,
+			want:   map[string]string{HeaderContentType: "text/plain"},
+		}, {
+			name:   "directory index",
+			path:   "/assets/docs/",
+			status: StatusOK,
+			out:    "<html>docs</html>",
+			want:   map[string]string{HeaderContentType: "text/html"},
+		}, {
+			name:   "content hash etag",
+			path:   "/assets/data/unknown.zzz",
+			status: StatusOK,
+			out:    "??",
+			want: map[string]string{
+				HeaderETag:         fmt.Sprintf(`"%x"`, sum[:16]),
+				HeaderLastModified: "",
+			},
+		}, {
+			name:   "missing",
+			path:   "/assets/nope.js",
+			status: StatusNotFound,
+		}, {
+			name:   "outside the root",
+			path:   "/assets/../../go.mod",
+			status: StatusNotFound,
+		}, {
+			name:   "root",
+			path:   "/",
+			status: StatusOK,
+			out:    "<html>home</html>",
+			want:   map[string]string{HeaderCacheControl: "no-cache"},
+		}, {
+			name:   "spa fallback",
+			path:   "/users/42/settings",
+			status: StatusOK,
+			out:    "<html>home</html>",
+			want: map[string]string{
+				HeaderCacheControl: "no-cache",
+				HeaderVary:         "",
+			},
+		}, {
+			name:   "routes win",
+			path:   "/api/users",
+			status: StatusOK,
+			out:    `{"key":"a","value":"b"}`,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			m := tst.method
+			if m == "" {
+				m = http.MethodGet
+			}
+			r := httptest.NewRequest(m, tst.path, nil)
+			for k, v := range tst.headers {
+				r.Header.Set(k, v)
+			}
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+			for k, v := range tst.want {
+				if got := rw.Header().Get(k); got != v {
+					t.Fatalf("%s: wanted: %q. got: %q", k, v, got)
+				}
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 1 {
+		t.Fatalf("static files documented: %v", doc.Paths)
+	}
+}
+
+func TestStaticNotFoundChain(t *testing.T) {
+	app := newTestApp(t)
+	app.Static("/", fstest.MapFS{"a.txt": {Data: []byte("a")}}, StaticOptions{})
+	app.Static("/", fstest.MapFS{"b.txt": {Data: []byte("b")}}, StaticOptions{})
+
+	type tt struct {
+		path   string
+		status int
+		out    string
+	}
+	tsts := []tt{
+		{path: "/b.txt", status: StatusOK, out: "b"},
+		{path: "/a.txt", status: StatusOK, out: "a"},
+		{path: "/c.txt", status: StatusNotFound},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, tst.path, nil))
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if tst.out != "" && rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package gate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	ContentTypeNDJSON ContentType = "application/x-ndjson"
+	ContentTypeBINARY ContentType = MIMEOctetStream
+)
+
+// Writes the response body incrementally. Every Write is flushed to
+// the client. Writes fail once the client disconnects; long running
+// handlers should also watch RequestCtx.Context.
+//
+// Errors returned before anything was written go through the app's
+// error handling like a Handler's. Later ones can only be logged.
+type StreamHandler func(*RequestCtx, io.WriteCloser) error
+
+// What an endpoint streams. Used for the Content-Type header and the
+// OpenAPI response
+type streamSpec struct {
+	contentType ContentType
+	item        Payload
+}
+
+// Creates an EndpointConfig for a StreamHandler. ct is sent as the
+// Content-Type of the response. item describes a single streamed
+// value in the OpenAPI document:
+//   - ContentTypeNDJSON documents the item's schema, one per line
+//   - ContentTypeJSON documents an array of items. See JSONArrayEncoder
+//   - Anything else documents a binary body and item may be nil
+//
+// The returned config can be customised further using the
+// EndpointConfig.With... methods and registered using App.Get, App.Post etc.
+func NewStreamEndpointConfig(path string, ct ContentType, item Payload, h StreamHandler) EndpointConfig {
+	ec := EndpointConfig{
+		Path:   path,
+		stream: &streamSpec{contentType: ct, item: item},
+	}
+	ec.Handler = func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		sw := newStreamWriter(rc, ct)
+		err := h(rc, sw)
+		if err != nil && !sw.started() {
+			return nil, err
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Println(wrapErr(err, "stream"))
+		}
+		if err := sw.Close(); err != nil && !errors.Is(err, context.Canceled) {
+			log.Println(wrapErr(err, "stream"))
+		}
+		return nil, nil
+	}
+	return ec
+}
+
+// The io.WriteCloser handed to a StreamHandler
+type streamWriter struct {
+	rc          *RequestCtx
+	contentType ContentType
+	mu          sync.Mutex
+	begun       bool
+	closed      bool
+}
+
+func newStreamWriter(rc *RequestCtx, ct ContentType) *streamWriter {
+	return &streamWriter{rc: rc, contentType: ct}
+}
+
+func (sw *streamWriter) started() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.begun
+}
+
+// Sends the headers on the first call
+func (sw *streamWriter) begin() {
+	if sw.begun {
+		return
+	}
+	sw.begun = true
+	rw := sw.rc.ResponseWriter
+	if rw.Header().Get(HeaderContentType) == "" {
+		rw.Header().Set(HeaderContentType, sw.contentType.String())
+	}
+	code := sw.rc.status
+	if code == 0 {
+		code = StatusOK
+	}
+	rw.WriteHeader(code)
+}
+
+func (sw *streamWriter) flush() {
+	rw := sw.rc.ResponseWriter
+	if _, ok := rw.rw.(http.Flusher); ok {
+		rw.Flush()
+	}
+}
+
+func (sw *streamWriter) Write(bs []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return 0, wrapErr(fmt.Errorf("write to a closed stream"))
+	}
+	if err := sw.rc.Context().Err(); err != nil {
+		return 0, err
+	}
+	sw.begin()
+	n, err := sw.rc.ResponseWriter.Write(bs)
+	if err != nil {
+		return n, err
+	}
+	sw.flush()
+	return n, nil
+}
+
+// Ends the stream. Headers are sent if nothing was written yet.
+// Closing more than once is a no-op.
+func (sw *streamWriter) Close() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	if err := sw.rc.Context().Err(); err != nil {
+		return err
+	}
+	if !sw.begun {
+		sw.begin()
+	}
+	sw.flush()
+	return nil
+}
+
+// Encodes v as JSON using c. Payloads gate provides use their JSON
+// encoding.
+func streamJSON(c Codec, v interface{}) ([]byte, error) {
+	if cp, ok := v.(codecPayload); ok {
+		return cp.encode(c)
+	}
+	if p, ok := v.(Payload); ok && mediaType(p.ContentType().String()) == MIMEApplicationJSON {
+		return p.Marshal()
+	}
+	return c.Encode(v)
+}
+
+// The app's JSON codec when w is the writer handed to a
+// StreamHandler. JSONCodec otherwise
+func streamCodec(w io.Writer) Codec {
+	if sw, ok := w.(*streamWriter); ok {
+		return sw.rc.jsonCodec()
+	}
+	return JSONCodec{}
+}
+
+// Writes values as newline delimited JSON
+type NDJSONEncoder struct {
+	w io.Writer
+	c Codec
+}
+
+// Values are encoded using the app's JSON codec when w is the
+// writer handed to a StreamHandler
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	return &NDJSONEncoder{w: w, c: streamCodec(w)}
+}
+
+// Writes v followed by a newline
+func (ne *NDJSONEncoder) Encode(v interface{}) error {
+	bs, err := streamJSON(ne.c, v)
+	if err != nil {
+		return wrapErr(err)
+	}
+	if _, err := ne.w.Write(append(bs, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Writes values as the elements of a single JSON array.
+// Close writes the closing bracket.
+type JSONArrayEncoder struct {
+	w      io.Writer
+	c      Codec
+	n      int
+	closed bool
+}
+
+// Values are encoded like NDJSONEncoder's
+func NewJSONArrayEncoder(w io.Writer) *JSONArrayEncoder {
+	return &JSONArrayEncoder{w: w, c: streamCodec(w)}
+}
+
+func (je *JSONArrayEncoder) Encode(v interface{}) error {
+	if je.closed {
+		return wrapErr(fmt.Errorf("encode after Close"))
+	}
+	bs, err := streamJSON(je.c, v)
+	if err != nil {
+		return wrapErr(err)
+	}
+	sep := byte(',')
+	if je.n == 0 {
+		sep = '['
+	}
+	if _, err := je.w.Write(append([]byte{sep}, bs...)); err != nil {
+		return err
+	}
+	je.n++
+	return nil
+}
+
+// Ends the array. The underlying writer is left open.
+func (je *JSONArrayEncoder) Close() error {
+	if je.closed {
+		return nil
+	}
+	je.closed = true
+	end := "]"
+	if je.n == 0 {
+		end = "[]"
+	}
+	_, err := je.w.Write([]byte(end))
+	return err
+}
+
+func streamResponse(sg *schemaGen, ss *streamSpec) (*openapi3.Response, error) {
+	res := openapi3.NewResponse().WithDescription(httpStatusMessage[StatusOK])
+
+	var sr *openapi3.SchemaRef
+	switch mediaType(ss.contentType.String()) {
+	case mediaType(ContentTypeNDJSON.String()):
+		if ss.item == nil {
+			return nil, wrapErr(fmt.Errorf("ndjson streams need an item payload"))
+		}
+		s, err := sg.payloadSchema(ss.item)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		sr = s
+		res.WithDescription("One JSON value per line")
+	case MIMEApplicationJSON:
+		if ss.item == nil {
+			return nil, wrapErr(fmt.Errorf("json streams need an item payload"))
+		}
+		s, err := sg.payloadSchema(ss.item)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		arr := openapi3.NewArraySchema()
+		arr.Items = s
+		sr = openapi3.NewSchemaRef("", arr)
+	case mediaType(ContentTypeEventStream.String()):
+		sr = openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+		res.WithDescription("Server-Sent Events")
+		if ss.item != nil {
+			s, err := sg.payloadSchema(ss.item)
+			if err != nil {
+				return nil, wrapErr(err)
+			}
+			sr = s
+			res.WithDescription("Server-Sent Events. Event data is described by the schema")
+		}
+	default:
+		sr = openapi3.NewSchemaRef("", openapi3.NewStringSchema().WithFormat("binary"))
+		if ss.item != nil {
+			s, err := sg.payloadSchema(ss.item)
+			if err != nil {
+				return nil, wrapErr(err)
+			}
+			sr = s
+		}
+	}
+	res.WithContent(openapi3.NewContentWithSchemaRef(sr, []string{ss.contentType.String()}))
+	return res, nil
+}
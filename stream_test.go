@@ -0,0 +1,160 @@
+package gate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamHandler(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(&Middleware{
+		ID: "tag",
+		Handler: func(h Handler) Handler {
+			return func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+				rc.ResponseWriter.Header().Set("X-Middleware", "yes")
+				return h(rc, rd)
+			}
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	items := []*testPld{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}
+	app.Get(NewStreamEndpointConfig("/ndjson", ContentTypeNDJSON, &testPld{}, func(rc *RequestCtx, w io.WriteCloser) error {
+		enc := NewNDJSONEncoder(w)
+		for _, it := range items {
+			if err := enc.Encode(it); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	app.Get(NewStreamEndpointConfig("/array", ContentTypeJSON, &testPld{}, func(rc *RequestCtx, w io.WriteCloser) error {
+		enc := NewJSONArrayEncoder(w)
+		for _, it := range items {
+			if err := enc.Encode(it); err != nil {
+				return err
+			}
+		}
+		return enc.Close()
+	}))
+	app.Get(NewStreamEndpointConfig("/empty", ContentTypeJSON, &testPld{}, func(rc *RequestCtx, w io.WriteCloser) error {
+		return NewJSONArrayEncoder(w).Close()
+	}))
+	app.Get(NewStreamEndpointConfig("/fail", ContentTypeBINARY, nil, func(rc *RequestCtx, w io.WriteCloser) error {
+		return NewError(StatusForbidden)
+	}))
+
+	type tt struct {
+		name   string
+		path   string
+		status int
+		ct     ContentType
+		out    string
+	}
+	tsts := []tt{
+		{
+			name:   "ndjson",
+			path:   "/ndjson",
+			status: StatusOK,
+			ct:     ContentTypeNDJSON,
+			out:    "{\"key\":\"a\",\"value\":\"1\"}\n{\"key\":\"b\",\"value\":\"2\"}\n",
+		}, {
+			name:   "json array",
+			path:   "/array",
+			status: StatusOK,
+			ct:     ContentTypeJSON,
+			out:    `[{"key":"a","value":"1"},{"key":"b","value":"2"}]`,
+		}, {
+			name:   "empty array",
+			path:   "/empty",
+			status: StatusOK,
+			ct:     ContentTypeJSON,
+			out:    `[]`,
+		}, {
+			name:   "error before writing",
+			path:   "/fail",
+			status: StatusForbidden,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tst.path, nil)
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, r)
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if rw.Header().Get("X-Middleware") != "yes" {
+				t.Fatalf("middleware didn't run")
+			}
+			if tst.status != StatusOK {
+				return
+			}
+			if !rw.Flushed {
+				t.Fatalf("stream not flushed")
+			}
+			if ct := rw.Header().Get(HeaderContentType); ct != tst.ct.String() {
+				t.Fatalf("wanted content type: %s. got: %s", tst.ct, ct)
+			}
+			if rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %q. got: %q", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := doc.Paths["/ndjson"].Get.Responses.Get(StatusOK).Value
+	if mt := res.Content.Get(ContentTypeNDJSON.String()); mt == nil || mt.Schema.Value.Properties["key"] == nil {
+		t.Fatalf("ndjson item not documented")
+	}
+	res = doc.Paths["/array"].Get.Responses.Get(StatusOK).Value
+	if mt := res.Content.Get(MIMEApplicationJSON); mt == nil || mt.Schema.Value.Items.Value.Properties["key"] == nil {
+		t.Fatalf("json array items not documented")
+	}
+	res = doc.Paths["/fail"].Get.Responses.Get(StatusOK).Value
+	if mt := res.Content.Get(MIMEOctetStream); mt == nil || mt.Schema.Value.Format != "binary" {
+		t.Fatalf("binary stream not documented")
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	app := newTestApp(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	written := 0
+	var streamErr error
+	app.Get(NewStreamEndpointConfig("/ticks", ContentTypeNDJSON, NewInt(0), func(rc *RequestCtx, w io.WriteCloser) error {
+		enc := NewNDJSONEncoder(w)
+		for i := 0; i < 10; i++ {
+			if i == 3 {
+				// The client goes away
+				cancel()
+			}
+			if err := enc.Encode(NewInt(i)); err != nil {
+				streamErr = err
+				return err
+			}
+			written++
+		}
+		return nil
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/ticks", nil).WithContext(ctx)
+	rw := httptest.NewRecorder()
+	app.ServeHTTP(rw, r)
+	if written != 3 {
+		t.Fatalf("wanted 3 items written. got: %d", written)
+	}
+	if streamErr != context.Canceled {
+		t.Fatalf("wanted: %v. got: %v", context.Canceled, streamErr)
+	}
+	if rw.Body.String() != "0\n1\n2\n" {
+		t.Fatalf("got: %q", rw.Body.String())
+	}
+}
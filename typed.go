@@ -0,0 +1,159 @@
+package gate
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Constrains type parameters to types whose pointer is a Payload
+type PayloadPtr[T any] interface {
+	*T
+	Payload
+}
+
+// A Handler that receives and returns concrete payload types.
+// Use NoPayload for any of Req, Query or Resp the endpoint doesn't have.
+// req and query are nil when their type is NoPayload.
+type TypedHandler[Req, Query, Resp any] func(rc *RequestCtx, req *Req, query *Query) (*Resp, error)
+
+// A payloadPool of *T. Values are zeroed before they're reused.
+type typedPool[T any, PT PayloadPtr[T]] struct {
+	pool sync.Pool
+}
+
+func newTypedPool[T any, PT PayloadPtr[T]]() *typedPool[T, PT] {
+	tp := &typedPool[T, PT]{}
+	tp.pool.New = func() interface{} {
+		return PT(new(T))
+	}
+	return tp
+}
+
+func (tp *typedPool[T, PT]) get() Payload {
+	return tp.pool.Get().(PT)
+}
+
+func (tp *typedPool[T, PT]) put(p Payload) {
+	v, ok := p.(PT)
+	if !ok {
+		return
+	}
+	var zero T
+	*v = zero
+	tp.pool.Put(v)
+}
+
+// Creates an EndpointConfig for a TypedHandler. Payload types are
+// taken from the type parameters so no sample instances are needed.
+// The returned config can be customised further using the
+// EndpointConfig.With... methods and registered using App.Get, App.Post etc.
+func NewTypedEndpointConfig[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](path string, h TypedHandler[Req, Query, Resp]) EndpointConfig {
+	ec := EndpointConfig{
+		Path: path,
+		Payload: EndpointPayload{
+			RequestPayload:  PReq(new(Req)),
+			QueryPayload:    PQuery(new(Query)),
+			ResponsePayload: PResp(new(Resp)),
+		},
+	}
+	if hasBody(ec.Payload.RequestPayload) {
+		ec.requestPool = newTypedPool[Req, PReq]()
+	}
+	if hasBody(ec.Payload.QueryPayload) {
+		ec.queryPool = newTypedPool[Query, PQuery]()
+	}
+
+	ec.Handler = func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		var (
+			req   *Req
+			query *Query
+		)
+		if rd.Body != nil {
+			v, ok := rd.Body.(PReq)
+			if !ok {
+				return nil, wrapErr(fmt.Errorf("request payload is %T. wanted %T", rd.Body, PReq(nil)))
+			}
+			req = (*Req)(v)
+		}
+		if rd.QueryParams != nil {
+			v, ok := rd.QueryParams.(PQuery)
+			if !ok {
+				return nil, wrapErr(fmt.Errorf("query payload is %T. wanted %T", rd.QueryParams, PQuery(nil)))
+			}
+			query = (*Query)(v)
+		}
+
+		res, err := h(rc, req, query)
+		if res == nil {
+			return nil, err
+		}
+		return PResp(res), err
+	}
+	return ec
+}
+
+// Registers a typed GET endpoint.
+// The payload types are inferred from h
+func Get[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Get(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
+
+// Registers a typed POST endpoint.
+// The payload types are inferred from h
+func Post[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Post(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
+
+// Registers a typed PUT endpoint.
+// The payload types are inferred from h
+func Put[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Put(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
+
+// Registers a typed PATCH endpoint.
+// The payload types are inferred from h
+func Patch[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Patch(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
+
+// Registers a typed DELETE endpoint.
+// The payload types are inferred from h
+func Delete[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Delete(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
+
+// Registers a typed OPTIONS endpoint.
+// The payload types are inferred from h
+func Options[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Options(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
+
+// Registers a typed HEAD endpoint.
+// The payload types are inferred from h
+func Head[
+	Req, Query, Resp any,
+	PReq PayloadPtr[Req], PQuery PayloadPtr[Query], PResp PayloadPtr[Resp],
+](app *App, path string, h TypedHandler[Req, Query, Resp]) {
+	app.Head(NewTypedEndpointConfig[Req, Query, Resp, PReq, PQuery, PResp](path, h))
+}
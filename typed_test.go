@@ -0,0 +1,115 @@
+package gate
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTypedEndpoints(t *testing.T) {
+	app := newTestApp(t)
+	app.Apply(&Middleware{
+		ID: "pina",
+		Handler: func(h Handler) Handler {
+			return func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+				rc.ResponseWriter.Header().Set("pina", "colada")
+				return h(rc, rd)
+			}
+		},
+	})
+
+	Post(app, "/echo/:name", func(rc *RequestCtx, req *testPld, q *NoPayload) (*testPld, error) {
+		if q != nil {
+			t.Errorf("NoPayload query must be nil")
+		}
+		return &testPld{Key: req.Key, Value: req.Value + " " + rc.Request.URL.Path}, nil
+	})
+	Get(app, "/empty", func(rc *RequestCtx, req *NoPayload, q *NoPayload) (*String, error) {
+		return nil, nil
+	})
+	app.Put(NewTypedEndpointConfig(
+		"/typed",
+		func(rc *RequestCtx, req *String, q *QueryPayload) (*String, error) {
+			return NewString(string(*req) + (*q)["k"][0]), nil
+		},
+	).WithSummary("typed put"))
+
+	type tt struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+		out    string
+	}
+	tsts := []tt{
+		{
+			name:   "post",
+			method: http.MethodPost,
+			url:    "/echo/a",
+			body:   `{"key":"k","value":"v"}`,
+			status: StatusOK,
+			out:    `{"key":"k","value":"v /echo/a"}`,
+		}, {
+			name:   "post again",
+			method: http.MethodPost,
+			url:    "/echo/b",
+			body:   `{"key":"k"}`,
+			status: StatusOK,
+			out:    `{"key":"k","value":" /echo/b"}`,
+		}, {
+			name:   "bad body",
+			method: http.MethodPost,
+			url:    "/echo/a",
+			body:   `"nope"`,
+			status: StatusBadRequest,
+		}, {
+			name:   "no payloads",
+			method: http.MethodGet,
+			url:    "/empty",
+			status: StatusOK,
+		}, {
+			name:   "query",
+			method: http.MethodPut,
+			url:    "/typed?k=v",
+			body:   `"k="`,
+			status: StatusOK,
+			out:    `"k=v"`,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(tst.method, tst.url, bytes.NewBufferString(tst.body)))
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d", tst.status, rw.Code)
+			}
+			if tst.status != StatusOK {
+				return
+			}
+			if rw.Header().Get("pina") != "colada" {
+				t.Fatalf("middleware not applied")
+			}
+			if rw.Body.String() != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, rw.Body.String())
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := doc.Paths["/echo/{name}"].Post
+	if post.RequestBody == nil || len(post.Responses.Get(StatusOK).Value.Content) != 1 {
+		t.Fatalf("typed payloads not documented")
+	}
+	get := doc.Paths["/empty"].Get
+	if get.RequestBody != nil || len(get.Parameters) != 0 {
+		t.Fatalf("NoPayload must not be documented")
+	}
+	if doc.Paths["/typed"].Put.Summary != "typed put" {
+		t.Fatalf("typed config lost its metadata")
+	}
+}
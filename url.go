@@ -0,0 +1,103 @@
+package gate
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// The paths of the named endpoints by name. Fails when a name is
+// used twice. Must hold app.mu
+func (app *App) names() (map[string]string, error) {
+	names := map[string]string{}
+	for _, v := range app.epCache {
+		ec := v.ec
+		if ec.Name == "" {
+			continue
+		}
+		if p, ok := names[ec.Name]; ok {
+			return nil, wrapErr(fmt.Errorf("name %q already used by %s", ec.Name, p), ec.method, ec.Path)
+		}
+		names[ec.Name] = ec.Path
+	}
+	return names, nil
+}
+
+// Builds the path of the endpoint named name. params are pairs of
+// param names and values filling the `:param` and `*catchall`
+// segments of its path:
+//
+//	app.URL("user", "id", "42") // "/users/42"
+//
+// Values are escaped. A catch-all value may span several segments.
+// Every param of the path must be given, and nothing else.
+func (app *App) URL(name string, params ...string) (string, error) {
+	if app == nil || app.router == nil {
+		return "", wrapErr(fmt.Errorf("app not initialized"))
+	}
+	if len(params)%2 != 0 {
+		return "", wrapErr(fmt.Errorf("params must be name value pairs"), name)
+	}
+	app.mu.Lock()
+	names, err := app.names()
+	app.mu.Unlock()
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	p, ok := names[name]
+	if !ok {
+		return "", wrapErr(fmt.Errorf("no endpoint named %q", name))
+	}
+
+	values := map[string]string{}
+	for i := 0; i < len(params); i += 2 {
+		if _, ok := values[params[i]]; ok {
+			return "", wrapErr(fmt.Errorf("param %q given twice", params[i]), name)
+		}
+		values[params[i]] = params[i+1]
+	}
+
+	var missing []string
+	take := func(segment string) (string, bool) {
+		pn := segment[2:]
+		v, ok := values[pn]
+		if !ok {
+			missing = append(missing, pn)
+		}
+		delete(values, pn)
+		return v, ok
+	}
+	ca := catchAllParam(p)
+	var empty []string
+	p = pathParamRegexp.ReplaceAllStringFunc(strings.TrimSuffix(p, ca), func(segment string) string {
+		v, ok := take(segment)
+		if ok && v == "" {
+			empty = append(empty, segment[2:])
+		}
+		return "/" + url.PathEscape(v)
+	})
+	if ca != "" {
+		v, _ := take(ca)
+		segments := strings.Split(strings.TrimPrefix(v, "/"), "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		p += "/" + strings.Join(segments, "/")
+	}
+
+	switch {
+	case len(missing) > 0:
+		return "", wrapErr(fmt.Errorf("missing params: %s", strings.Join(missing, ", ")), name)
+	case len(empty) > 0:
+		return "", wrapErr(fmt.Errorf("empty params: %s", strings.Join(empty, ", ")), name)
+	case len(values) > 0:
+		extra := make([]string, 0, len(values))
+		for k := range values {
+			extra = append(extra, k)
+		}
+		sort.Strings(extra)
+		return "", wrapErr(fmt.Errorf("unknown params: %s", strings.Join(extra, ", ")), name)
+	}
+	return p, nil
+}
@@ -0,0 +1,132 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/users/:id", testHandler).WithName("user"))
+	app.Get(NewEndpointConfig("/users/:id/posts/:post", testHandler).WithName("post"))
+	app.Get(NewEndpointConfig("/users", testHandler).WithName("users"))
+	app.Group("/v1").Get(NewEndpointConfig("/files/:owner/*path", testHandler).WithName("file"))
+	app.Post(NewEndpointConfig("/users", func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		loc, err := app.URL("user", "id", "7")
+		if err != nil {
+			return nil, err
+		}
+		rc.ResponseWriter.Header().Set(HeaderLocation, loc)
+		rc.SetStatus(StatusCreated)
+		return nil, nil
+	}))
+
+	type tt struct {
+		name   string
+		route  string
+		params []string
+		out    string
+		err    string
+	}
+	tsts := []tt{
+		{
+			name:   "param",
+			route:  "user",
+			params: []string{"id", "42"},
+			out:    "/users/42",
+		}, {
+			name:   "several params",
+			route:  "post",
+			params: []string{"post", "p 1", "id", "a/b"},
+			out:    "/users/a%2Fb/posts/p%201",
+		}, {
+			name:  "no params",
+			route: "users",
+			out:   "/users",
+		}, {
+			name:   "catch-all",
+			route:  "file",
+			params: []string{"owner", "me", "path", "/docs/a b.txt"},
+			out:    "/v1/files/me/docs/a%20b.txt",
+		}, {
+			name:   "missing",
+			route:  "post",
+			params: []string{"id", "1"},
+			err:    "missing params: post",
+		}, {
+			name:   "empty",
+			route:  "user",
+			params: []string{"id", ""},
+			err:    "empty params: id",
+		}, {
+			name:   "extra",
+			route:  "user",
+			params: []string{"id", "1", "b", "2", "a", "3"},
+			err:    "unknown params: a, b",
+		}, {
+			name:   "twice",
+			route:  "user",
+			params: []string{"id", "1", "id", "2"},
+			err:    `param "id" given twice`,
+		}, {
+			name:   "odd",
+			route:  "user",
+			params: []string{"id"},
+			err:    "name value pairs",
+		}, {
+			name:  "unknown name",
+			route: "nope",
+			err:   `no endpoint named "nope"`,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			out, err := app.URL(tst.route, tst.params...)
+			if tst.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tst.err) {
+					t.Fatalf("wanted error: %q. got: %v", tst.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tst.out {
+				t.Fatalf("wanted: %s. got: %s", tst.out, out)
+			}
+		})
+	}
+
+	t.Run("location", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/users", nil)
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, r)
+		if rw.Code != StatusCreated || rw.Header().Get(HeaderLocation) != "/users/7" {
+			t.Fatalf("got: %d %q", rw.Code, rw.Header().Get(HeaderLocation))
+		}
+	})
+}
+
+func TestURLDuplicateName(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/a", testHandler).WithName("a"))
+	app.Post(NewEndpointConfig("/b", testHandler).WithName("a"))
+	if _, err := app.OpenAPI(); err == nil || !strings.Contains(err.Error(), `name "a" already used`) {
+		t.Fatalf("wanted duplicate name error. got: %v", err)
+	}
+}
+
+func TestURLMountFailure(t *testing.T) {
+	app := newTestApp(t)
+	app.Get(NewEndpointConfig("/a", testHandler).
+		WithName("a").
+		WithParams(NewPathParam("missing", ParamTypeString)))
+	for i := 0; i < 2; i++ {
+		_, err := app.OpenAPI()
+		if err == nil || strings.Contains(err.Error(), "already used") {
+			t.Fatalf("wanted the mount error. got: %v", err)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package gate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Implemented by request payloads that check themselves after
+// being unmarshalled. Return a *ValidationError to report
+// individual fields. Any other error is reported as is.
+type Validator interface {
+	Validate() error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Answered with StatusUnprocessableEntity when a request payload
+// fails validation. Handlers can return it too.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (ve *ValidationError) Add(field, message string) {
+	ve.Errors = append(ve.Errors, FieldError{Field: field, Message: message})
+}
+
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, len(ve.Errors))
+	for i, fe := range ve.Errors {
+		msgs[i] = fe.Message
+		if fe.Field != "" {
+			msgs[i] = fe.Field + ": " + fe.Message
+		}
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (ve ValidationError) Marshal() ([]byte, error) {
+	return ve.encode(JSONCodec{})
+}
+
+func (ve ValidationError) encode(c Codec) ([]byte, error) {
+	bs, err := c.Encode(ve)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return bs, nil
+}
+
+func (ve *ValidationError) Unmarshal(src []byte) error {
+	return ve.decode(JSONCodec{}, src)
+}
+
+func (ve *ValidationError) decode(c Codec, src []byte) error {
+	var v ValidationError
+	if err := c.Decode(src, &v); err != nil {
+		return wrapErr(err)
+	}
+	*ve = v
+	return nil
+}
+
+func (ValidationError) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+// Constraints declared on a struct field. The tags are:
+//
+//	validate:"required,min=1,max=10" - required fails on zero values.
+//	                                   min and max bound numbers, the length
+//	                                   of strings and the size of slices and maps
+//	pattern:"^[a-z]+$"               - strings must match it
+//	enum:"a,b"                       - the value must be one of these
+//	format:"email"                   - strings must be an email or, with "uuid", a UUID
+//
+// Zero values only fail required. The other rules skip them.
+type fieldRules struct {
+	required bool
+	min      *float64
+	max      *float64
+	pattern  string
+	enum     []string
+	format   string
+}
+
+func (fr fieldRules) empty() bool {
+	return !fr.required && fr.min == nil && fr.max == nil &&
+		fr.pattern == "" && len(fr.enum) == 0 && fr.format == ""
+}
+
+func parseFieldRules(f reflect.StructField) (fieldRules, error) {
+	var fr fieldRules
+	if v := f.Tag.Get("validate"); v != "" {
+		for _, r := range strings.Split(v, ",") {
+			k, arg := r, ""
+			if i := strings.Index(r, "="); i >= 0 {
+				k, arg = r[:i], r[i+1:]
+			}
+			switch k {
+			case "required":
+				fr.required = true
+			case "min", "max":
+				n, err := strconv.ParseFloat(arg, 64)
+				if err != nil {
+					return fr, wrapErr(fmt.Errorf("invalid %s %q", k, arg))
+				}
+				if k == "min" {
+					fr.min = &n
+				} else {
+					fr.max = &n
+				}
+			default:
+				return fr, wrapErr(fmt.Errorf("unknown validate rule %q", k))
+			}
+		}
+	}
+
+	ft := f.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if p, ok := f.Tag.Lookup("pattern"); ok {
+		if _, err := compilePattern(p); err != nil {
+			return fr, wrapErr(err)
+		}
+		fr.pattern = p
+	}
+	if e, ok := f.Tag.Lookup("enum"); ok {
+		fr.enum = strings.Split(e, ",")
+	}
+	// format is a time layout for time.Time fields. See tagField
+	if ft.Kind() == reflect.String {
+		switch format := f.Tag.Get("format"); format {
+		case "", "email", "uuid":
+			fr.format = format
+		default:
+			return fr, wrapErr(fmt.Errorf("unknown format %q", format))
+		}
+	}
+	return fr, nil
+}
+
+// Applies fr to the schema generated for a field
+func (fr fieldRules) applySchema(s *openapi3.Schema, typ reflect.Type) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case s.Type == openapi3.TypeString:
+		if fr.min != nil {
+			s.MinLength = uint64(*fr.min)
+		}
+		if fr.max != nil {
+			max := uint64(*fr.max)
+			s.MaxLength = &max
+		}
+	case s.Type == openapi3.TypeArray:
+		if fr.min != nil {
+			s.MinItems = uint64(*fr.min)
+		}
+		if fr.max != nil {
+			max := uint64(*fr.max)
+			s.MaxItems = &max
+		}
+	case s.Type == openapi3.TypeObject && typ.Kind() == reflect.Map:
+		if fr.min != nil {
+			s.MinProps = uint64(*fr.min)
+		}
+		if fr.max != nil {
+			max := uint64(*fr.max)
+			s.MaxProps = &max
+		}
+	case s.Type == openapi3.TypeInteger || s.Type == openapi3.TypeNumber:
+		if fr.min != nil {
+			s.Min = fr.min
+		}
+		if fr.max != nil {
+			s.Max = fr.max
+		}
+	}
+
+	if fr.pattern != "" {
+		s.Pattern = fr.pattern
+	}
+	switch fr.format {
+	case "email":
+		s.Format = "email"
+	case "uuid":
+		s.Pattern = uuidPattern
+	}
+	for _, e := range fr.enum {
+		s.Enum = append(s.Enum, queryDefault(typ, tagField{def: []string{e}}))
+	}
+}
+
+type ruleField struct {
+	index []int
+	name  string
+	rules fieldRules
+}
+
+type ruleSet struct {
+	fields []ruleField
+	// Struct fields that may have rules of their own
+	nested []ruleField
+	err    error
+}
+
+var ruleCache sync.Map
+
+// The validated fields of typ. Names are the json ones so that
+// reports match what clients send.
+func typeRules(typ reflect.Type) *ruleSet {
+	if rs, ok := ruleCache.Load(typ); ok {
+		return rs.(*ruleSet)
+	}
+
+	rs := &ruleSet{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || f.Tag.Get("json") == "-" {
+			continue
+		}
+		name, _, named := jsonFieldName(f)
+		if f.Anonymous && !named || f.Tag.Get("gate") == "inline" {
+			// Promoted fields are reported without the embedded type's name
+			name = ""
+		}
+		fr, err := parseFieldRules(f)
+		if err != nil {
+			rs.err = wrapErr(err, f.Name)
+			break
+		}
+		rf := ruleField{index: f.Index, name: name, rules: fr}
+		if !fr.empty() {
+			rs.fields = append(rs.fields, rf)
+		}
+		if containsStruct(f.Type) {
+			rs.nested = append(rs.nested, rf)
+		}
+	}
+	ruleCache.Store(typ, rs)
+	return rs
+}
+
+func containsStruct(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && typ != timeType
+}
+
+// Whether a payload of type typ is validated before reaching the handler
+func validates(typ reflect.Type) bool {
+	if typ == nil {
+		return false
+	}
+	if reflect.PtrTo(typ).Implements(validatorType) || typ.Implements(validatorType) {
+		return true
+	}
+	return hasRules(typ, map[reflect.Type]bool{})
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+func hasRules(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return false
+	}
+	seen[typ] = true
+	rs := typeRules(typ)
+	if len(rs.fields) > 0 {
+		return true
+	}
+	for _, n := range rs.nested {
+		if hasRules(typ.FieldByIndex(n.index).Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks p against its struct tags and then calls its Validate
+// method if it has one. Returns a *ValidationError listing every
+// failing field.
+func validatePayload(p Payload) error {
+	ve := &ValidationError{}
+	rv := reflect.ValueOf(p)
+	if err := validateValue(rv, "", ve); err != nil {
+		return wrapErr(err)
+	}
+
+	if v, ok := p.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			if e, ok := err.(*ValidationError); ok {
+				ve.Errors = append(ve.Errors, e.Errors...)
+			} else {
+				ve.Add("", err.Error())
+			}
+		}
+	}
+
+	if len(ve.Errors) > 0 {
+		return ve
+	}
+	return nil
+}
+
+func validateValue(rv reflect.Value, path string, ve *ValidationError) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if !containsStruct(rv.Type()) {
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := validateValue(rv.Index(i), fmt.Sprintf("%s[%d]", path, i), ve); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	rs := typeRules(rv.Type())
+	if rs.err != nil {
+		return rs.err
+	}
+	for _, f := range rs.fields {
+		if msg := f.rules.check(rv.FieldByIndex(f.index)); msg != "" {
+			ve.Add(joinFieldPath(path, f.name), msg)
+		}
+	}
+	for _, f := range rs.nested {
+		if err := validateValue(rv.FieldByIndex(f.index), joinFieldPath(path, f.name), ve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinFieldPath(path, name string) string {
+	if name == "" {
+		return path
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Returns a message describing why fv breaks fr. Empty if it doesn't
+func (fr fieldRules) check(fv reflect.Value) string {
+	if fv.IsZero() {
+		if fr.required {
+			return "required"
+		}
+		return ""
+	}
+	for fv.Kind() == reflect.Ptr {
+		fv = fv.Elem()
+	}
+
+	var (
+		n    float64
+		what = "value"
+	)
+	switch fv.Kind() {
+	case reflect.String:
+		n, what = float64(utf8.RuneCountInString(fv.String())), "length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, what = float64(fv.Len()), "size"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = fv.Float()
+	}
+	if fr.min != nil && n < *fr.min {
+		return fmt.Sprintf("%s must be at least %v", what, *fr.min)
+	}
+	if fr.max != nil && n > *fr.max {
+		return fmt.Sprintf("%s must be at most %v", what, *fr.max)
+	}
+
+	if fv.Kind() == reflect.String {
+		s := fv.String()
+		if fr.pattern != "" {
+			re, _ := compilePattern(fr.pattern)
+			if !re.MatchString(s) {
+				return fmt.Sprintf("must match %s", fr.pattern)
+			}
+		}
+		switch fr.format {
+		case "email":
+			if a, err := mail.ParseAddress(s); err != nil || a.Address != s {
+				return "must be an email address"
+			}
+		case "uuid":
+			if _, err := ParseUUID(s); err != nil {
+				return "must be a UUID"
+			}
+		}
+	}
+
+	if len(fr.enum) > 0 {
+		if err := checkEnum([]string{fmt.Sprint(fv.Interface())}, fr.enum); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
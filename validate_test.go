@@ -0,0 +1,146 @@
+package gate
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	json "github.com/goccy/go-json"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testSignup struct {
+	Name      string        `json:"name" validate:"required,min=2,max=10"`
+	Email     string        `json:"email" format:"email"`
+	Age       int           `json:"age" validate:"min=18,max=130"`
+	Role      string        `json:"role,omitempty" enum:"admin,user"`
+	Code      string        `json:"code,omitempty" pattern:"^[A-Z]{3}$"`
+	Tags      []string      `json:"tags,omitempty" validate:"max=2"`
+	Addresses []testAddress `json:"addresses,omitempty"`
+	Password  string        `json:"password,omitempty"`
+	Confirm   string        `json:"confirm,omitempty"`
+	Nick      string        `json:"nick" validate:"min=3"`
+	Score     *int          `json:"score,omitempty" validate:"min=1"`
+}
+
+func (ts testSignup) Marshal() ([]byte, error) {
+	return json.Marshal(ts)
+}
+
+func (ts *testSignup) Unmarshal(src []byte) error {
+	return json.Unmarshal(src, ts)
+}
+
+func (testSignup) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func (ts *testSignup) Validate() error {
+	if ts.Password != ts.Confirm {
+		ve := &ValidationError{}
+		ve.Add("confirm", "does not match password")
+		return ve
+	}
+	return nil
+}
+
+func TestValidation(t *testing.T) {
+	app := newTestApp(t)
+	app.Post(EndpointConfig{
+		Path: "/signup",
+		Handler: func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+			return NewString("ok"), nil
+		},
+		Payload: EndpointPayload{
+			RequestPayload:  &testSignup{},
+			ResponsePayload: NewString(""),
+		},
+	})
+
+	type tt struct {
+		name   string
+		body   string
+		status int
+		fields []string
+	}
+	tsts := []tt{
+		{
+			name:   "valid",
+			body:   `{"name":"ann","email":"ann@example.com","age":20,"role":"admin","code":"ABC","addresses":[{"city":"x"}],"nick":"annie"}`,
+			status: StatusOK,
+		}, {
+			name:   "required",
+			body:   `{"email":"ann@example.com","age":20,"nick":"annie"}`,
+			status: StatusUnprocessableEntity,
+			fields: []string{"name"},
+		}, {
+			name:   "everything wrong",
+			body:   `{"name":"a","email":"nope","age":3,"role":"root","code":"abc","tags":["a","b","c"],"addresses":[{"city":"x"},{}],"password":"a","nick":"ab","score":-1}`,
+			status: StatusUnprocessableEntity,
+			fields: []string{"name", "email", "age", "role", "code", "tags", "nick", "score", "addresses[1].city", "confirm"},
+		}, {
+			name:   "optional and empty",
+			body:   `{"name":"ann","email":"","age":0,"nick":""}`,
+			status: StatusOK,
+		}, {
+			name:   "present zero",
+			body:   `{"name":"ann","score":0}`,
+			status: StatusUnprocessableEntity,
+			fields: []string{"score"},
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			app.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBufferString(tst.body)))
+			if rw.Code != tst.status {
+				t.Fatalf("wanted: %d. got: %d. %s", tst.status, rw.Code, rw.Body.String())
+			}
+			if tst.status == StatusOK {
+				return
+			}
+
+			var ve ValidationError
+			if err := ve.Unmarshal(rw.Body.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, fe := range ve.Errors {
+				got = append(got, fe.Field)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tst.fields) {
+				t.Fatalf("wanted: %v. got: %v", tst.fields, got)
+			}
+		})
+	}
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Paths["/signup"].Post
+	if op.Responses.Get(StatusUnprocessableEntity) == nil {
+		t.Fatalf("422 not documented")
+	}
+	props := op.RequestBody.Value.Content.Get(ContentTypeJSON.String()).Schema.Value.Properties
+	if s := props["name"].Value; s.MinLength != 2 || s.MaxLength == nil || *s.MaxLength != 10 {
+		t.Fatalf("name length not documented")
+	}
+	if s := props["age"].Value; s.Min == nil || *s.Min != 18 {
+		t.Fatalf("age min not documented")
+	}
+	if s := props["email"].Value; s.Format != "email" {
+		t.Fatalf("email format not documented")
+	}
+	if s := props["role"].Value; len(s.Enum) != 2 {
+		t.Fatalf("role enum not documented")
+	}
+	if s := props["tags"].Value; s.MaxItems == nil || *s.MaxItems != 2 {
+		t.Fatalf("tags max not documented")
+	}
+}
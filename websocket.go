@@ -0,0 +1,462 @@
+package gate
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Type of a WebSocket data message
+type MessageType int
+
+const (
+	MessageText   MessageType = 1
+	MessageBinary MessageType = 2
+)
+
+// WebSocket close codes. RFC 6455, 7.4.1
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseAbnormal        = 1006
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseMandatoryExt    = 1010
+	CloseInternalError   = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+const (
+	websocketGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultMaxMessageSize = 16 << 20
+	// Control frames are limited to 125 bytes. RFC 6455, 5.5
+	maxControlPayload = 125
+)
+
+// Returned by reads once the connection is closing. Code is the
+// close code the peer sent or the one gate closed with after a
+// protocol violation.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (ce *CloseError) Error() string {
+	if ce.Reason == "" {
+		return fmt.Sprintf("websocket closed: %d", ce.Code)
+	}
+	return fmt.Sprintf("websocket closed: %d %s", ce.Code, ce.Reason)
+}
+
+// Returned by writes after a close frame was sent
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// Handles an upgraded connection. The connection is closed with
+// CloseNormal when it returns nil and CloseInternalError otherwise.
+type WebSocketHandler func(rc *RequestCtx, conn *WebSocketConn) error
+
+// A server side WebSocket connection. Reads must happen from one
+// goroutine at a time. Writes may happen from several.
+type WebSocketConn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	reg       *codecRegistry
+	readLimit int64
+	onPong    func([]byte)
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+// Add a GET endpoint upgrading requests to WebSocket connections.
+// Upgrade requests go through the app's middlewares before the
+// connection is hijacked so those can reject them as usual.
+func (app *App) WebSocket(path string, h WebSocketHandler) {
+	app.Get(app.websocketConfig(path, h))
+}
+
+func (app *App) websocketConfig(path string, h WebSocketHandler) EndpointConfig {
+	return NewEndpointConfig(path, func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+		return nil, app.upgrade(rc, h)
+	}).WithResponse(StatusSwitchingProtocols, nil)
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Checks the handshake, hijacks the connection and runs h.
+// Errors are only returned before the connection is hijacked.
+func (app *App) upgrade(rc *RequestCtx, h WebSocketHandler) error {
+	r := rc.Request
+	if !headerHasToken(r.Header, HeaderConnection, "upgrade") ||
+		!headerHasToken(r.Header, HeaderUpgrade, "websocket") {
+		rc.ResponseWriter.Header().Set(HeaderUpgrade, "websocket")
+		return NewError(StatusUpgradeRequired, "websocket upgrade required")
+	}
+	if r.Header.Get(HeaderSecWebSocketVersion) != "13" {
+		rc.ResponseWriter.Header().Set(HeaderSecWebSocketVersion, "13")
+		return NewError(StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get(HeaderSecWebSocketKey)
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		return NewError(StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	conn, brw, err := rc.ResponseWriter.Hijack()
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	// Headers set by middlewares are sent along
+	hdr := rc.ResponseWriter.Header().Clone()
+	hdr.Del(HeaderContentType)
+	hdr.Del(HeaderContentLength)
+	hdr.Set(HeaderUpgrade, "websocket")
+	hdr.Set(HeaderConnection, "Upgrade")
+	hdr.Set(HeaderSecWebSocketAccept, websocketAccept(key))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	hdr.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		log.Println(wrapErr(err, "websocket handshake"))
+		return nil
+	}
+
+	wc := &WebSocketConn{
+		conn:      conn,
+		br:        brw.Reader,
+		reg:       app.codecs,
+		readLimit: defaultMaxMessageSize,
+	}
+	defer conn.Close()
+	if err := h(rc, wc); err != nil {
+		var ce *CloseError
+		switch {
+		case errors.As(err, &ce), errors.Is(err, ErrWebSocketClosed):
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			// The client went away without a close frame
+			return nil
+		default:
+			log.Println(wrapErr(err, "websocket"))
+			wc.Close(CloseInternalError, "")
+			return nil
+		}
+	}
+	wc.Close(CloseNormal, "")
+	return nil
+}
+
+// Messages larger than n bytes close the connection with
+// CloseMessageTooBig. Defaults to 16 MiB
+func (c *WebSocketConn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+// Called with the data of every pong received
+func (c *WebSocketConn) SetPongHandler(f func(data []byte)) {
+	c.onPong = f
+}
+
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+type wsFrame struct {
+	fin     bool
+	op      byte
+	payload []byte
+}
+
+// Sends a close frame with code and returns the matching error
+func (c *WebSocketConn) fail(code int, reason string) error {
+	c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+func (c *WebSocketConn) readFrame(limit int64) (wsFrame, error) {
+	var f wsFrame
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return f, err
+	}
+	f.fin = h[0]&0x80 != 0
+	f.op = h[0] & 0x0f
+	if h[0]&0x70 != 0 {
+		return f, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if h[1]&0x80 == 0 {
+		return f, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		u := binary.BigEndian.Uint64(ext[:])
+		if u>>63 != 0 {
+			return f, c.fail(CloseProtocolError, "invalid frame length")
+		}
+		n = int64(u)
+	}
+
+	if f.op >= opClose {
+		if !f.fin || n > maxControlPayload {
+			return f, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if n > limit {
+		return f, c.fail(CloseMessageTooBig, "")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// Reads the next data message. Pings are answered and control
+// frames handled while waiting. Once the peer closes the connection
+// or breaks the protocol a *CloseError is returned.
+func (c *WebSocketConn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		mt      MessageType
+		msg     []byte
+		started bool
+	)
+	for {
+		f, err := c.readFrame(c.readLimit - int64(len(msg)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.op {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil && err != ErrWebSocketClosed {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			if c.onPong != nil {
+				c.onPong(f.payload)
+			}
+			continue
+		case opClose:
+			return 0, nil, c.closeReceived(f.payload)
+		case opContinuation:
+			if !started {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case opText, opBinary:
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			started = true
+			mt = MessageType(f.op)
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		msg = append(msg, f.payload...)
+		if f.fin {
+			if mt == MessageText && !utf8.Valid(msg) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+			}
+			return mt, msg, nil
+		}
+	}
+}
+
+// Answers the peer's close frame with the same code
+func (c *WebSocketConn) closeReceived(payload []byte) error {
+	switch {
+	case len(payload) == 0:
+		c.Close(CloseNormal, "")
+		return &CloseError{Code: CloseNoStatus}
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	reason := payload[2:]
+	if !validCloseCode(code) {
+		return c.fail(CloseProtocolError, "invalid close code")
+	}
+	if !utf8.Valid(reason) {
+		return c.fail(CloseInvalidPayload, "invalid close reason")
+	}
+	c.Close(code, "")
+	return &CloseError{Code: code, Reason: string(reason)}
+}
+
+func (c *WebSocketConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	hdr := make([]byte, 2, 10)
+	hdr[0] = 0x80 | op
+	switch n := len(payload); {
+	case n <= 125:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = hdr[:4]
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = hdr[:10]
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	if _, err := c.conn.Write(append(hdr, payload...)); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+func (c *WebSocketConn) WriteMessage(mt MessageType, data []byte) error {
+	switch mt {
+	case MessageText:
+		if !utf8.Valid(data) {
+			return wrapErr(fmt.Errorf("text messages must be valid utf-8"))
+		}
+		return c.writeFrame(opText, data)
+	case MessageBinary:
+		return c.writeFrame(opBinary, data)
+	}
+	return wrapErr(fmt.Errorf("unknown message type %d", mt))
+}
+
+// Whether payloads of ct are sent as text messages
+func textContentType(ct ContentType) bool {
+	mt := mediaType(ct.String())
+	return strings.HasPrefix(mt, "text/") ||
+		mt == MIMEApplicationJSON || mt == MIMEApplicationXML ||
+		strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
+
+// Reads the next message into p using the codec of p's ContentType
+func (c *WebSocketConn) ReadPayload(p Payload) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if err := (payloadCodec{ct: p.ContentType(), reg: c.reg}).Decode(data, p); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// Writes p using the codec of its ContentType. Textual content
+// types are sent as text messages and the rest as binary ones.
+func (c *WebSocketConn) WritePayload(p Payload) error {
+	bs, err := (payloadCodec{ct: p.ContentType(), reg: c.reg}).Encode(p)
+	if err != nil {
+		return wrapErr(err)
+	}
+	mt := MessageBinary
+	if textContentType(p.ContentType()) {
+		mt = MessageText
+	}
+	return c.WriteMessage(mt, bs)
+}
+
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return wrapErr(fmt.Errorf("ping data longer than %d bytes", maxControlPayload))
+	}
+	return c.writeFrame(opPing, data)
+}
+
+// Sends a close frame. Later writes fail with ErrWebSocketClosed.
+// The connection itself is closed once the handler returns.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+		for !utf8.Valid(payload[2:]) {
+			payload = payload[:len(payload)-1]
+		}
+	}
+	err := c.writeFrame(opClose, payload)
+	if err == ErrWebSocketClosed {
+		return nil
+	}
+	return err
+}
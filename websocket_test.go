@@ -0,0 +1,247 @@
+package gate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A minimal client side of the protocol
+type testWSClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialTestWS(t *testing.T, srv *httptest.Server, path string, hdr http.Header) (*testWSClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Header.Set(HeaderConnection, "Upgrade")
+	req.Header.Set(HeaderUpgrade, "websocket")
+	req.Header.Set(HeaderSecWebSocketVersion, "13")
+	req.Header.Set(HeaderSecWebSocketKey, "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testWSClient{conn: conn, br: br}, res
+}
+
+func (c *testWSClient) write(t *testing.T, fin bool, op byte, payload []byte) {
+	t.Helper()
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testWSClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(h[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return h[0] & 0x0f, payload
+}
+
+func (c *testWSClient) readClose(t *testing.T) int {
+	t.Helper()
+	op, payload := c.read(t)
+	if op != opClose || len(payload) < 2 {
+		t.Fatalf("wanted a close frame. got: %x %q", op, payload)
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func TestWebSocket(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Apply(&Middleware{
+		ID: "auth",
+		Handler: func(h Handler) Handler {
+			return func(rc *RequestCtx, rd *RequestData) (Payload, error) {
+				if rc.Request.Header.Get(HeaderAuthorization) != "token" {
+					return nil, NewError(StatusUnauthorized)
+				}
+				rc.ResponseWriter.Header().Set("X-Middleware", "yes")
+				return h(rc, rd)
+			}
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error, 1)
+	app.WebSocket("/echo", func(rc *RequestCtx, conn *WebSocketConn) error {
+		conn.SetReadLimit(1024)
+		for {
+			var p testPld
+			if err := conn.ReadPayload(&p); err != nil {
+				closed <- err
+				return err
+			}
+			p.Value += "!"
+			if err := conn.WritePayload(&p); err != nil {
+				return err
+			}
+		}
+	})
+	app.WebSocket("/fail", func(rc *RequestCtx, conn *WebSocketConn) error {
+		return errors.New("boom")
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	auth := http.Header{HeaderAuthorization: {"token"}}
+
+	t.Run("middleware rejects", func(t *testing.T) {
+		_, res := dialTestWS(t, srv, "/echo", nil)
+		if res.StatusCode != StatusUnauthorized {
+			t.Fatalf("wanted: %d. got: %d", StatusUnauthorized, res.StatusCode)
+		}
+	})
+
+	t.Run("not an upgrade", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/echo", nil)
+		r.Header.Set(HeaderAuthorization, "token")
+		rw := httptest.NewRecorder()
+		app.ServeHTTP(rw, r)
+		if rw.Code != StatusUpgradeRequired {
+			t.Fatalf("wanted: %d. got: %d", StatusUpgradeRequired, rw.Code)
+		}
+	})
+
+	t.Run("echo", func(t *testing.T) {
+		c, res := dialTestWS(t, srv, "/echo", auth)
+		if res.StatusCode != StatusSwitchingProtocols {
+			t.Fatalf("wanted: %d. got: %d", StatusSwitchingProtocols, res.StatusCode)
+		}
+		if a := res.Header.Get(HeaderSecWebSocketAccept); a != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Fatalf("wrong accept key: %s", a)
+		}
+		if res.Header.Get("X-Middleware") != "yes" {
+			t.Fatalf("middleware headers not sent")
+		}
+
+		// Fragmented with a ping in between
+		c.write(t, false, opText, []byte(`{"key":"k",`))
+		c.write(t, true, opPing, []byte("hi"))
+		c.write(t, true, opContinuation, []byte(`"value":"v"}`))
+		if op, payload := c.read(t); op != opPong || string(payload) != "hi" {
+			t.Fatalf("wanted pong. got: %x %q", op, payload)
+		}
+		if op, payload := c.read(t); op != opText || string(payload) != `{"key":"k","value":"v!"}` {
+			t.Fatalf("wanted echo. got: %x %q", op, payload)
+		}
+
+		c.write(t, true, opClose, []byte{0x03, 0xe8})
+		if code := c.readClose(t); code != CloseNormal {
+			t.Fatalf("wanted: %d. got: %d", CloseNormal, code)
+		}
+		var ce *CloseError
+		if err := <-closed; !errors.As(err, &ce) || ce.Code != CloseNormal {
+			t.Fatalf("handler got: %v", err)
+		}
+	})
+
+	type tt struct {
+		name string
+		send func(c *testWSClient)
+		code int
+	}
+	tsts := []tt{
+		{
+			name: "unmasked frame",
+			send: func(c *testWSClient) {
+				c.conn.Write([]byte{0x81, 0x01, 'a'})
+			},
+			code: CloseProtocolError,
+		}, {
+			name: "invalid utf-8",
+			send: func(c *testWSClient) {
+				c.write(t, true, opText, []byte{0xff, 0xfe})
+			},
+			code: CloseInvalidPayload,
+		}, {
+			name: "too big",
+			send: func(c *testWSClient) {
+				c.write(t, true, opBinary, []byte(strings.Repeat("a", 2000)))
+			},
+			code: CloseMessageTooBig,
+		}, {
+			name: "unexpected continuation",
+			send: func(c *testWSClient) {
+				c.write(t, true, opContinuation, []byte("a"))
+			},
+			code: CloseProtocolError,
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.name, func(t *testing.T) {
+			c, _ := dialTestWS(t, srv, "/echo", auth)
+			tst.send(c)
+			if code := c.readClose(t); code != tst.code {
+				t.Fatalf("wanted: %d. got: %d", tst.code, code)
+			}
+			<-closed
+		})
+	}
+
+	t.Run("handler error", func(t *testing.T) {
+		c, _ := dialTestWS(t, srv, "/fail", auth)
+		if code := c.readClose(t); code != CloseInternalError {
+			t.Fatalf("wanted: %d. got: %d", CloseInternalError, code)
+		}
+	})
+
+	doc, err := app.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Paths["/echo"].Get.Responses.Get(StatusSwitchingProtocols) == nil {
+		t.Fatalf("upgrade response not documented")
+	}
+}